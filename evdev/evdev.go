@@ -0,0 +1,80 @@
+// Package evdev reads Linux input devices from /dev/input/event*,
+// it only covers what's needed for controllers and pedals.
+package evdev
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrUnsupported = errors.New("evdev is only supported on Linux")
+
+// Event types
+const (
+	EV_SYN = 0x00
+	EV_KEY = 0x01
+	EV_ABS = 0x03
+	EV_MSC = 0x04
+)
+
+const SYN_REPORT = 0
+
+// microseconds since an arbitrary point, set by the driver for each sample
+const MSC_TIMESTAMP = 0x05
+
+// Absolute axes
+const (
+	ABS_X     = 0x00
+	ABS_Y     = 0x01
+	ABS_Z     = 0x02
+	ABS_RX    = 0x03
+	ABS_RY    = 0x04
+	ABS_RZ    = 0x05
+	ABS_HAT0X = 0x10
+	ABS_HAT0Y = 0x11
+)
+
+// Gamepad buttons
+const (
+	BTN_SOUTH      = 0x130
+	BTN_EAST       = 0x131
+	BTN_NORTH      = 0x133
+	BTN_WEST       = 0x134
+	BTN_Z          = 0x135
+	BTN_TL         = 0x136
+	BTN_TR         = 0x137
+	BTN_TL2        = 0x138
+	BTN_TR2        = 0x139
+	BTN_SELECT     = 0x13a
+	BTN_START      = 0x13b
+	BTN_MODE       = 0x13c
+	BTN_THUMBL     = 0x13d
+	BTN_THUMBR     = 0x13e
+	BTN_DPAD_UP    = 0x220
+	BTN_DPAD_DOWN  = 0x221
+	BTN_DPAD_LEFT  = 0x222
+	BTN_DPAD_RIGHT = 0x223
+)
+
+// One input event, a group of them ends with EV_SYN/SYN_REPORT
+type Event struct {
+	Time  time.Time
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+func (ev *Event) String() string {
+	return fmt.Sprintf("type: %d, code: 0x%x, value: %d", ev.Type, ev.Code, ev.Value)
+}
+
+// Range of an absolute axis
+type AbsInfo struct {
+	Value      int32
+	Minimum    int32
+	Maximum    int32
+	Fuzz       int32
+	Flat       int32
+	Resolution int32 // units per mm, per g for accelerometers, per degree/s for gyroscopes
+}
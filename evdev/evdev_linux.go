@@ -0,0 +1,178 @@
+package evdev
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// struct input_event
+type rawEvent struct {
+	Time  unix.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+const rawEventSize = int(unsafe.Sizeof(rawEvent{}))
+
+// from linux/input.h
+func ioc(dir, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | 'E'<<8 | nr
+}
+
+const (
+	iocWrite = 1
+	iocRead  = 2
+)
+
+func eviocgname(size int) uintptr { return ioc(iocRead, 0x06, uintptr(size)) }
+func eviocguniq(size int) uintptr { return ioc(iocRead, 0x08, uintptr(size)) }
+func eviocgabs(code uint16) uintptr {
+	return ioc(iocRead, 0x40+uintptr(code), unsafe.Sizeof(AbsInfo{}))
+}
+func eviocgrab() uintptr { return ioc(iocWrite, 0x90, unsafe.Sizeof(int32(0))) }
+
+type Device struct {
+	path string
+	f    *os.File
+
+	name string
+	uniq string // the MAC for Bluetooth devices, may be empty
+}
+
+// All event nodes, sorted by number
+func List() ([]string, error) {
+	paths, e := filepath.Glob("/dev/input/event*")
+	if e != nil {
+		return nil, e
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) < len(paths[j])
+		}
+		return paths[i] < paths[j]
+	})
+	return paths, nil
+}
+
+// The name of an event node from sysfs, without opening it
+func NameOf(path string) (string, error) {
+	b, e := os.ReadFile(filepath.Join("/sys/class/input", filepath.Base(path), "device", "name"))
+	if e != nil {
+		return "", e
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func Open(path string) (*Device, error) {
+	f, e := os.OpenFile(path, os.O_RDONLY, 0)
+	if e != nil {
+		return nil, e
+	}
+	d := &Device{path: path, f: f}
+
+	if d.name, e = d.getString(eviocgname); e != nil {
+		f.Close()
+		return nil, e
+	}
+	d.uniq, _ = d.getString(eviocguniq) // not all devices have it
+	return d, nil
+}
+
+func (d *Device) Path() string { return d.path }
+func (d *Device) Name() string { return d.name }
+func (d *Device) Uniq() string { return d.uniq }
+
+// The input device directory in sysfs, e.g. /sys/class/input/event5/device
+func (d *Device) SysPath() string {
+	return filepath.Join("/sys/class/input", filepath.Base(d.path), "device")
+}
+
+// Run the syscall on the fd without `f.Fd()`, which switches the file to blocking mode,
+// then `Close()` can't interrupt a pending `Read()`
+func (d *Device) control(fn func(fd uintptr) unix.Errno) (e error) {
+	conn, e := d.f.SyscallConn()
+	if e != nil {
+		return e
+	}
+	ctrlErr := conn.Control(func(fd uintptr) {
+		if errno := fn(fd); errno != 0 {
+			e = errno
+		}
+	})
+	if ctrlErr != nil {
+		return ctrlErr
+	}
+	return e
+}
+
+func (d *Device) ioctl(req uintptr, arg unsafe.Pointer) error {
+	return d.control(func(fd uintptr) unix.Errno {
+		_, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, req, uintptr(arg))
+		return errno
+	})
+}
+
+func (d *Device) getString(req func(int) uintptr) (string, error) {
+	var buf [256]byte
+	if e := d.ioctl(req(len(buf)), unsafe.Pointer(&buf[0])); e != nil {
+		return "", e
+	}
+	return strings.TrimRight(string(buf[:]), "\x00"), nil
+}
+
+func (d *Device) AbsInfo(code uint16) (AbsInfo, error) {
+	var info AbsInfo
+	e := d.ioctl(eviocgabs(code), unsafe.Pointer(&info))
+	return info, e
+}
+
+// Take the device exclusively, other programs(including the desktop) stop receiving its events
+func (d *Device) Grab(grab bool) error {
+	var v uintptr
+	if grab {
+		v = 1
+	}
+	return d.control(func(fd uintptr) unix.Errno {
+		_, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, eviocgrab(), v)
+		return errno
+	})
+}
+
+// Blocking read of the available events, at least one
+func (d *Device) Read() ([]Event, error) {
+	buf := make([]byte, rawEventSize*64)
+	n, e := d.f.Read(buf)
+	if e != nil {
+		return nil, e
+	}
+
+	var raw rawEvent
+	events := make([]Event, 0, n/rawEventSize)
+	r := bytes.NewReader(buf[:n-n%rawEventSize])
+	for r.Len() > 0 {
+		if e = binary.Read(r, binary.LittleEndian, &raw); e != nil {
+			return nil, e
+		}
+		events = append(events, Event{
+			Time:  time.Unix(int64(raw.Time.Sec), int64(raw.Time.Usec)*1000),
+			Type:  raw.Type,
+			Code:  raw.Code,
+			Value: raw.Value,
+		})
+	}
+	return events, nil
+}
+
+// Interrupts the pending `Read()`
+func (d *Device) Close() error {
+	return d.f.Close()
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package evdev
+
+type Device struct{}
+
+func List() ([]string, error) { return nil, ErrUnsupported }
+
+func NameOf(path string) (string, error) { return "", ErrUnsupported }
+
+func Open(path string) (*Device, error) { return nil, ErrUnsupported }
+
+func (d *Device) Path() string                         { return "" }
+func (d *Device) Name() string                         { return "" }
+func (d *Device) Uniq() string                         { return "" }
+func (d *Device) SysPath() string                      { return "" }
+func (d *Device) AbsInfo(code uint16) (AbsInfo, error) { return AbsInfo{}, ErrUnsupported }
+func (d *Device) Grab(grab bool) error                 { return ErrUnsupported }
+func (d *Device) Read() ([]Event, error)               { return nil, ErrUnsupported }
+func (d *Device) Close() error                         { return nil }
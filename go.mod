@@ -7,6 +7,7 @@ require (
 	github.com/arturoeanton/go-notify v1.0.4
 	github.com/c-bata/go-prompt v0.2.6
 	github.com/fatih/color v1.13.0
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/gen2brain/beeep v0.0.0-20220909211152-5a9ec94374f6
 	github.com/gen2brain/malgo v0.10.35
 	github.com/go-vgo/robotgo v1.0.0-beta5.3.0.20220906215318-4ad733fe8361
@@ -19,6 +20,7 @@ require (
 	github.com/sstallion/go-hid v0.0.0-20211019232252-c64377bfa49e
 	github.com/stretchr/testify v1.8.0
 	golang.org/x/exp v0.0.0-20221006183845-316c7553db56
+	golang.org/x/sys v0.0.0-20220908164124-27713097b956
 	tinygo.org/x/bluetooth v0.6.0
 )
 
@@ -26,7 +28,6 @@ require (
 	github.com/alexflint/go-scalar v1.1.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fatih/structs v1.1.0 // indirect
-	github.com/fsnotify/fsnotify v1.6.0 // indirect
 	github.com/go-ole/go-ole v1.2.6 // indirect
 	github.com/go-toast/toast v0.0.0-20190211030409-01e6764cf0a4 // indirect
 	github.com/godbus/dbus/v5 v5.1.0 // indirect
@@ -55,6 +56,5 @@ require (
 	github.com/vcaesar/tt v0.20.0 // indirect
 	github.com/yusufpapurcu/wmi v1.2.2 // indirect
 	golang.org/x/image v0.0.0-20211028202545-6944b10bf410 // indirect
-	golang.org/x/sys v0.0.0-20220908164124-27713097b956 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
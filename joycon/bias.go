@@ -0,0 +1,67 @@
+package joycon
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// Resting means the gyro only shakes this much around its recent average(deg/s)
+	stillNoise = 1.5
+	// and the average isn't far from the current bias(deg/s), not a slow steady turning
+	stillMaxBias = 5.0
+	// and the gravity stays ~1G(G)
+	stillAccel = 0.05
+	// for this long, then the bias starts learning
+	stillDuration = time.Second
+
+	// smoothing factors per sample
+	biasMeanRate  = 0.1  // the recent average
+	biasLearnRate = 0.01 // the bias
+)
+
+// BiasEstimator learns the gyro offset while the controller is resting,
+// and removes it from the samples, so the cursor doesn't creep.
+type BiasEstimator struct {
+	bias AngularVelocity // learned offset
+
+	mean  AngularVelocity // recent average of the raw rate
+	still time.Duration   // how long it has been resting
+}
+
+func (b *BiasEstimator) Bias() AngularVelocity        { return b.bias }
+func (b *BiasEstimator) SetBias(bias AngularVelocity) { b.bias = bias }
+
+// Learn from one sample, and remove the bias from it
+func (b *BiasEstimator) Compensate(rot *AngularVelocity, acc *Vector3, dt time.Duration) {
+	raw := [3]float64{rot.Roll, rot.Pitch, rot.Yaw}
+	mean := [3]*float64{&b.mean.Roll, &b.mean.Pitch, &b.mean.Yaw}
+	bias := [3]*float64{&b.bias.Roll, &b.bias.Pitch, &b.bias.Yaw}
+
+	gravity := math.Sqrt(acc.X*acc.X + acc.Y*acc.Y + acc.Z*acc.Z)
+	resting := math.Abs(gravity-1) < stillAccel
+
+	for i := 0; i < 3; i++ {
+		*mean[i] += biasMeanRate * (raw[i] - *mean[i])
+
+		if math.Abs(raw[i]-*mean[i]) > stillNoise || math.Abs(*mean[i]-*bias[i]) > stillMaxBias {
+			resting = false
+		}
+	}
+
+	if resting {
+		b.still += dt
+	} else {
+		b.still = 0
+	}
+
+	if b.still >= stillDuration {
+		for i := 0; i < 3; i++ {
+			*bias[i] += biasLearnRate * (raw[i] - *bias[i])
+		}
+	}
+
+	rot.Roll -= b.bias.Roll
+	rot.Pitch -= b.bias.Pitch
+	rot.Yaw -= b.bias.Yaw
+}
@@ -0,0 +1,31 @@
+package joycon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBiasEstimator(t *testing.T) {
+	b := &BiasEstimator{}
+	flat := &Vector3{Z: 1}
+
+	// resting with 1.2 deg/s drift on yaw, 5 seconds
+	var rot AngularVelocity
+	for i := 0; i < 1000; i++ {
+		rot = AngularVelocity{Yaw: 1.2, Roll: -0.5}
+		b.Compensate(&rot, flat, SampleInterval)
+	}
+	assert.InDelta(t, 1.2, b.Bias().Yaw, 0.05)
+	assert.InDelta(t, -0.5, b.Bias().Roll, 0.05)
+	assert.InDelta(t, 0, rot.Yaw, 0.05)
+
+	// turning, it doesn't learn
+	learned := b.Bias()
+	for i := 0; i < 1000; i++ {
+		rot = AngularVelocity{Yaw: 90}
+		b.Compensate(&rot, flat, SampleInterval)
+	}
+	assert.Equal(t, learned, b.Bias())
+	assert.InDelta(t, 88.8, rot.Yaw, 0.05)
+}
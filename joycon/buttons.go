@@ -125,6 +125,16 @@ func (b ButtonState) Has(i ButtonID) bool {
 	return b[(i&0x0300)>>8]&byte(i&0xFF) != 0
 }
 
+// Set marks a single ButtonID as pressed.
+func (b *ButtonState) Set(i ButtonID) {
+	b[(i&0x0300)>>8] |= byte(i & 0xFF)
+}
+
+// Clear marks a single ButtonID as released.
+func (b *ButtonState) Clear(i ButtonID) {
+	b[(i&0x0300)>>8] &^= byte(i & 0xFF)
+}
+
 // DownMask returns buttons that being pressed down
 func (b ButtonState) DownMask(other ButtonState) ButtonState {
 	var result ButtonState
@@ -0,0 +1,185 @@
+package joycon
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A capture file is plain text, so it can be attached to a bug report directly:
+//
+//	# joy-typing capture side=2 mac=70:48:f7:76:bc:87
+//	0.015021 < 30a18e000000...
+//	2.001270 > 01010000000000000000...
+//
+// Each line is: seconds since the capture begins, direction, report in hex.
+// "<" is an input report(from the controller), ">" is an output report(to the controller).
+const (
+	captureHeader = "# joy-typing capture"
+	captureInput  = "<"
+	captureOutput = ">"
+)
+
+// Recorder wraps a Transport and writes every report that passes through it to a capture file.
+type Recorder struct {
+	Transport
+
+	mu    sync.Mutex
+	w     io.WriteCloser
+	begin time.Time
+}
+
+func NewRecorder(
+	t Transport, w io.WriteCloser,
+	side JoyConSide, mac string,
+) (*Recorder, error) {
+	r := &Recorder{
+		Transport: t,
+		w:         w,
+		begin:     time.Now(),
+	}
+	_, e := fmt.Fprintf(w, "%s side=%d mac=%s\n", captureHeader, side, mac)
+	return r, e
+}
+
+func (r *Recorder) save(dir string, report []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.w == nil {
+		return
+	}
+	fmt.Fprintf(r.w, "%.6f %s %x\n", time.Since(r.begin).Seconds(), dir, report)
+}
+
+func (r *Recorder) Read(report []byte) (int, error) {
+	n, e := r.Transport.Read(report)
+	if e == nil && n > 0 {
+		r.save(captureInput, report[:n])
+	}
+	return n, e
+}
+
+func (r *Recorder) Write(report []byte) (int, error) {
+	r.save(captureOutput, report)
+	return r.Transport.Write(report)
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	if r.w != nil {
+		r.w.Close()
+		r.w = nil
+	}
+	r.mu.Unlock()
+
+	return r.Transport.Close()
+}
+
+// Replay is a Transport that feeds the input reports of a capture file back to the reader,
+// output reports are dropped.
+type Replay struct {
+	side JoyConSide
+	mac  string
+
+	// wait between reports as they were captured,
+	// otherwise reports are returned as fast as possible
+	realtime bool
+
+	r       io.Reader
+	scanner *bufio.Scanner
+	begin   time.Time
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func NewReplay(r io.Reader, realtime bool) (*Replay, error) {
+	rp := &Replay{
+		r:        r,
+		scanner:  bufio.NewScanner(r),
+		realtime: realtime,
+		closed:   make(chan struct{}),
+	}
+	rp.scanner.Buffer(make([]byte, 0x1000), 0x1000)
+
+	if !rp.scanner.Scan() {
+		return nil, errors.New("empty capture file")
+	}
+	header := rp.scanner.Text()
+	if !strings.HasPrefix(header, captureHeader) {
+		return nil, fmt.Errorf("not a capture file: %s", header)
+	}
+	for _, field := range strings.Fields(header[len(captureHeader):]) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "side":
+			side, e := strconv.Atoi(kv[1])
+			if e != nil {
+				return nil, fmt.Errorf("wrong side in capture header: %s", kv[1])
+			}
+			rp.side = JoyConSide(side)
+		case "mac":
+			rp.mac = kv[1]
+		}
+	}
+	rp.begin = time.Now()
+	return rp, nil
+}
+
+// The side and mac of the captured controller
+func (rp *Replay) Side() JoyConSide { return rp.side }
+func (rp *Replay) Mac() string      { return rp.mac }
+
+func (rp *Replay) Read(report []byte) (int, error) {
+	for rp.scanner.Scan() {
+		fields := strings.Fields(rp.scanner.Text())
+		if len(fields) != 3 || fields[1] != captureInput {
+			continue
+		}
+		if rp.realtime {
+			sec, e := strconv.ParseFloat(fields[0], 64)
+			if e != nil {
+				return 0, fmt.Errorf("wrong timestamp: %s", fields[0])
+			}
+			wait := time.Until(rp.begin.Add(time.Duration(sec * float64(time.Second))))
+			select {
+			case <-time.After(wait):
+			case <-rp.closed:
+				return 0, io.EOF
+			}
+		}
+		data, e := hex.DecodeString(fields[2])
+		if e != nil {
+			return 0, fmt.Errorf("wrong report: %s", fields[2])
+		}
+		return copy(report, data), nil
+	}
+	if e := rp.scanner.Err(); e != nil {
+		return 0, e
+	}
+	return 0, io.EOF
+}
+
+func (rp *Replay) Write(report []byte) (int, error) {
+	return len(report), nil
+}
+
+func (rp *Replay) Close() error {
+	rp.closeOnce.Do(func() {
+		close(rp.closed)
+		if c, ok := rp.r.(io.Closer); ok {
+			c.Close()
+		}
+	})
+	return nil
+}
@@ -7,16 +7,32 @@ type Controller interface {
 	Disconnect()
 	ShutdownBT() error
 
-	// Bind events to listener,
+	// Bind events to listener, there can be many.
+	// It's called from the read loop, a slow one delays all events,
 	// Call the returned function to unbind.
-	SetListener(EventListener) RemoveListenerFn
+	Subscribe(EventListener) RemoveListenerFn
+	// Events through a buffered channel, for slow or high rate consumers,
+	// `drop` decides what to do with stick/gyro events when it's full.
+	// The channel is closed when unbound.
+	SubscribeChan(buffer int, drop DropPolicy) (<-chan Event, RemoveListenerFn)
 
 	Battery() (level int8, charging bool) // 4=full, 3, 2, 1=critical, 0=empty
 	EnableGyro(isOn bool) error
+	GyroBias() AngularVelocity
+	SetGyroBias(AngularVelocity)
 	Rumble(*RumbleFrequency) error
-	SetLights(pattern byte)
+	PlayRumble(RumblePattern)
+	SetLights(pattern byte) error
+	SetHomeLight(*HomeLight) error
 
 	CalibrateStick() error
+	RawStick() [2]Point
+	SetStickRanges([2]*StickRange)
+	StickDrift() [2]Ratio // [left, right], offsets removed from the sticks
+	CalibrateIMU() error
+
+	Stats() ReportStats
+	ResetStats()
 
 	Test()
 }
@@ -32,8 +48,21 @@ type EventListener interface {
 	OnStick(jc Controller, t JoyConSide, curr, prev *Ratio)
 	// stick calibrated successfylly
 	OnStickCalib(Controller, *[2]CalibrationData)
-	// gyro motion, todo
-	OnGyro(Controller, *GyroFrame)
+	// stick drifted and re-centered, `offset` is removed from now on
+	OnStickDrift(jc Controller, t JoyConSide, offset *Ratio)
+	// gyro motion, the side tells which IMU it comes from
+	OnGyro(jc Controller, t JoyConSide, frame *GyroFrame)
 	// battery level change
 	OnBattery(jc Controller, level int8, charging bool)
 }
+
+// Used when no listener is bound, it drops all events.
+type nopListener struct{}
+
+func (nopListener) OnReadWriteError(Controller, error)                     {}
+func (nopListener) OnButton(jc Controller, down, up, curr *ButtonState)    {}
+func (nopListener) OnStick(jc Controller, t JoyConSide, curr, prev *Ratio) {}
+func (nopListener) OnStickCalib(Controller, *[2]CalibrationData)           {}
+func (nopListener) OnStickDrift(Controller, JoyConSide, *Ratio)            {}
+func (nopListener) OnGyro(jc Controller, t JoyConSide, frame *GyroFrame)   {}
+func (nopListener) OnBattery(jc Controller, level int8, charging bool)     {}
@@ -9,6 +9,13 @@ const (
 	JOYCON_PRODUCT_CHARGEGRIP uint16 = 0x200e
 )
 
+// corresponding product -> side
+var ProductSide = map[uint16]JoyConSide{
+	JOYCON_PRODUCT_L:   SideLeft,
+	JOYCON_PRODUCT_R:   SideRight,
+	JOYCON_PRODUCT_PRO: SideBoth, // it has both sticks, all buttons of both sides except SL/SR
+}
+
 type JoyConSide int
 
 const (
@@ -38,6 +45,7 @@ func (s JoyConSide) String() string {
 	return "Unknown Device"
 }
 
+// Used in config, the Pro Controller's sticks are also addressed as "Left"/"Right"
 var SideMap = map[string]JoyConSide{
 	"Left":  SideLeft,
 	"Right": SideRight,
@@ -0,0 +1,87 @@
+package joycon
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// Drifting means the stick only shakes this much(ratio) while sitting off-center
+	driftNoise = 0.02
+	// and it's off-center by more than this(ratio), smaller ones don't matter
+	driftMin = 0.02
+	// but less than this(ratio), otherwise it's held by the thumb
+	driftMax = 0.1
+	// for this long, without any button activity
+	driftDuration = 3 * time.Second
+)
+
+// DriftEstimator finds the offset of a drifting stick,
+// which sits steadily off-center when released, and removes it.
+// Only the position it settles at after springing back is learned,
+// a small deflection held steadily by the thumb isn't.
+type DriftEstimator struct {
+	offset Ratio // the correction applied
+
+	// it's pushed beyond `driftMax` since the last steady period,
+	// the next steady one follows a return toward neutral
+	pushed bool
+
+	anchor Ratio     // the first sample of the steady period
+	since  time.Time // when the steady period starts, zero if not steady
+	sumX   float64
+	sumY   float64
+	count  int
+}
+
+func (d *DriftEstimator) Offset() Ratio { return d.offset }
+
+func (d *DriftEstimator) Reset() {
+	d.since = time.Time{}
+}
+
+// Learn from one sample, remove the offset from it.
+// `busy` is true if any button is pressed, the stick may be in use.
+// Returns true if the offset is changed.
+func (d *DriftEstimator) Correct(r *Ratio, busy bool, now time.Time) (changed bool) {
+	raw := *r
+	r.X -= d.offset.X
+	r.Y -= d.offset.Y
+
+	if math.Hypot(raw.X, raw.Y) >= driftMax {
+		d.pushed = true
+	}
+
+	if busy || d.since.IsZero() ||
+		math.Hypot(raw.X-d.anchor.X, raw.Y-d.anchor.Y) > driftNoise {
+		// start over from this sample
+		d.anchor, d.since = raw, now
+		d.sumX, d.sumY, d.count = 0, 0, 0
+		if busy {
+			d.since = time.Time{}
+		}
+		return false
+	}
+
+	d.sumX += raw.X
+	d.sumY += raw.Y
+	d.count++
+
+	if now.Sub(d.since) < driftDuration {
+		return false
+	}
+	mean := Ratio{d.sumX / float64(d.count), d.sumY / float64(d.count)}
+	d.Reset()
+
+	returned := d.pushed
+	d.pushed = false
+
+	if !returned || // not released, may be held by the thumb
+		math.Hypot(mean.X, mean.Y) >= driftMax || // held by the thumb
+		math.Hypot(mean.X-d.offset.X, mean.Y-d.offset.Y) <= driftMin { // already corrected
+		return false
+	}
+	d.offset = mean
+	r.X, r.Y = raw.X-mean.X, raw.Y-mean.Y
+	return true
+}
@@ -0,0 +1,88 @@
+package joycon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriftEstimator(t *testing.T) {
+	now := time.Now()
+	feed := func(d *DriftEstimator, r Ratio, busy bool, dur time.Duration) (Ratio, bool) {
+		changed := false
+		for end := now.Add(dur); now.Before(end); now = now.Add(15 * time.Millisecond) {
+			x := r
+			if d.Correct(&x, busy, now) {
+				changed = true
+			}
+		}
+		x := r
+		d.Correct(&x, busy, now)
+		return x, changed
+	}
+
+	d := &DriftEstimator{}
+	drifted := Ratio{0.06, -0.03}
+
+	// held there steadily, not released from a push
+	_, changed := feed(d, drifted, false, 2*driftDuration)
+	assert.False(t, changed)
+
+	// pushed and released
+	feed(d, Ratio{1, 0}, false, 0)
+
+	// not long enough
+	_, changed = feed(d, drifted, false, time.Second)
+	assert.False(t, changed)
+
+	// sits there for long, re-centered
+	r, changed := feed(d, drifted, false, 2*driftDuration)
+	assert.True(t, changed)
+	assert.InDelta(t, 0, r.X, 1e-9)
+	assert.InDelta(t, 0, r.Y, 1e-9)
+	assert.InDelta(t, drifted.X, d.Offset().X, 1e-9)
+	assert.InDelta(t, drifted.Y, d.Offset().Y, 1e-9)
+
+	// button pressed, the stick may be in use
+	d = &DriftEstimator{}
+	feed(d, Ratio{1, 0}, false, 0)
+	_, changed = feed(d, drifted, true, 2*driftDuration)
+	assert.False(t, changed)
+
+	// too far, it's held by the thumb
+	_, changed = feed(d, Ratio{0.5, 0}, false, 2*driftDuration)
+	assert.False(t, changed)
+
+	// shaking
+	for i := 0; i < 1000; i++ {
+		r := Ratio{0.1, 0}
+		if i%2 == 0 {
+			r.X += 0.05
+		}
+		assert.False(t, d.Correct(&r, false, now))
+		now = now.Add(15 * time.Millisecond)
+	}
+}
+
+func TestStickDrift(t *testing.T) {
+	p := &probe{}
+	jc := newTestJoycon(SideRight, p)
+	jc.handleSubcommandReply(newCalibReply()) // factory: 0x800 +- 0x500
+
+	// pushed, released and resting at 0x870 for a while
+	jc.decodeStick(newReport(ButtonState{}, 0xc00, 0x800))
+	jc.decodeStick(newReport(ButtonState{}, 0x870, 0x800))
+	jc.drift[1].since = jc.drift[1].since.Add(-driftDuration)
+	jc.decodeStick(newReport(ButtonState{}, 0x870, 0x800))
+	drift := jc.StickDrift()
+	assert.InDelta(t, 0x70/float64(0x500), drift[1].X, 1e-9)
+	assert.Equal(t, 1, p.drifts)
+
+	// re-centered
+	assert.InDelta(t, 0, p.sticks[len(p.sticks)-1].X, 1e-9)
+
+	// reset by a new range
+	jc.SetStickRanges([2]*StickRange{})
+	assert.Equal(t, [2]Ratio{}, jc.StickDrift())
+}
@@ -0,0 +1,649 @@
+package joycon
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aj3423/joy-typing/evdev"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+* The hid-nintendo kernel driver(Linux 5.16+) claims the controller and creates 2 evdev nodes:
+*   "Nintendo Switch Left Joy-Con":       buttons and sticks, calibrated by the driver
+*   "Nintendo Switch Left Joy-Con (IMU)": accelerometer and gyroscope, in physical units
+* Both have the MAC as `uniq`. Rumble and lights are left to the driver, they're not supported.
+ */
+
+var ErrEvdevUnsupported = errors.New("not supported by the evdev backend")
+
+const evdevNamePrefix = "Nintendo Switch "
+
+var evdevSides = map[string]JoyConSide{
+	"Left Joy-Con":   SideLeft,
+	"Right Joy-Con":  SideRight,
+	"Pro Controller": SideBoth,
+}
+
+// key code => button, of each controller
+var evdevButtons = map[JoyConSide]map[uint16]ButtonID{
+	SideLeft: {
+		evdev.BTN_TL:         Button_L_L,
+		evdev.BTN_TL2:        Button_L_ZL,
+		evdev.BTN_SELECT:     Button_Minus,
+		evdev.BTN_THUMBL:     Button_L_Stick,
+		evdev.BTN_Z:          Button_Capture,
+		evdev.BTN_DPAD_UP:    Button_L_Up,
+		evdev.BTN_DPAD_DOWN:  Button_L_Down,
+		evdev.BTN_DPAD_LEFT:  Button_L_Left,
+		evdev.BTN_DPAD_RIGHT: Button_L_Right,
+		evdev.BTN_TR:         Button_L_SL,
+		evdev.BTN_TR2:        Button_L_SR,
+	},
+	SideRight: {
+		evdev.BTN_EAST:   Button_R_A,
+		evdev.BTN_SOUTH:  Button_R_B,
+		evdev.BTN_NORTH:  Button_R_X,
+		evdev.BTN_WEST:   Button_R_Y,
+		evdev.BTN_TR:     Button_R_R,
+		evdev.BTN_TR2:    Button_R_ZR,
+		evdev.BTN_START:  Button_Plus,
+		evdev.BTN_THUMBR: Button_R_Stick,
+		evdev.BTN_MODE:   Button_Home,
+		evdev.BTN_TL:     Button_R_SL,
+		evdev.BTN_TL2:    Button_R_SR,
+	},
+	SideBoth: {
+		evdev.BTN_EAST:       Button_R_A,
+		evdev.BTN_SOUTH:      Button_R_B,
+		evdev.BTN_NORTH:      Button_R_X,
+		evdev.BTN_WEST:       Button_R_Y,
+		evdev.BTN_TL:         Button_L_L,
+		evdev.BTN_TL2:        Button_L_ZL,
+		evdev.BTN_TR:         Button_R_R,
+		evdev.BTN_TR2:        Button_R_ZR,
+		evdev.BTN_SELECT:     Button_Minus,
+		evdev.BTN_START:      Button_Plus,
+		evdev.BTN_THUMBL:     Button_L_Stick,
+		evdev.BTN_THUMBR:     Button_R_Stick,
+		evdev.BTN_MODE:       Button_Home,
+		evdev.BTN_Z:          Button_Capture,
+		evdev.BTN_DPAD_UP:    Button_L_Up,
+		evdev.BTN_DPAD_DOWN:  Button_L_Down,
+		evdev.BTN_DPAD_LEFT:  Button_L_Left,
+		evdev.BTN_DPAD_RIGHT: Button_L_Right,
+	},
+}
+
+// stick axes, [left, right][x, y]
+var evdevStickAxes = [2][2]uint16{
+	{evdev.ABS_X, evdev.ABS_Y},
+	{evdev.ABS_RX, evdev.ABS_RY},
+}
+
+// The driver reports calibrated sticks, they're mapped to 12 bits like the raw ones,
+// so the measured `StickRange` works the same way.
+const evdevStickCenter = 2048
+
+var evdevStickCalib = CalibrationData{
+	xMinOff: evdevStickCenter - 1, xCenter: evdevStickCenter, xMaxOff: evdevStickCenter - 1,
+	yMinOff: evdevStickCenter - 1, yCenter: evdevStickCenter, yMaxOff: evdevStickCenter - 1,
+}
+
+// the battery is read from sysfs, it's not in the events
+const evdevBatteryInterval = 30 * time.Second
+
+var evdevBatteryLevels = map[string]int8{
+	"Full": 4, "High": 3, "Normal": 2, "Low": 1, "Critical": 0,
+}
+
+// The evdev nodes of one controller
+type EvdevNodes struct {
+	Side  JoyConSide
+	Mac   string
+	Input string // path of the buttons/sticks node
+	Imu   string // path of the IMU node, empty if not found
+}
+
+// Find the controllers handled by hid-nintendo.
+// The "Combined Joy-Cons" device created by joycond is skipped, the halves are used instead.
+func FindEvdevNodes() ([]*EvdevNodes, error) {
+	paths, e := evdev.List()
+	if e != nil {
+		return nil, e
+	}
+
+	byMac := map[string]*EvdevNodes{}
+	ret := []*EvdevNodes{}
+	imus := map[string]string{} // mac => IMU node
+
+	for _, path := range paths {
+		dev, e := evdev.Open(path)
+		if e != nil {
+			continue // no permission, or it's gone
+		}
+		name, mac := dev.Name(), dev.Uniq()
+		dev.Close()
+
+		if !strings.HasPrefix(name, evdevNamePrefix) || mac == "" {
+			continue
+		}
+		model := strings.TrimPrefix(name, evdevNamePrefix)
+		if strings.HasSuffix(model, "(IMU)") {
+			imus[mac] = path
+			continue
+		}
+		side, ok := evdevSides[model]
+		if !ok {
+			continue
+		}
+		if _, exist := byMac[mac]; !exist {
+			byMac[mac] = &EvdevNodes{Side: side, Mac: mac, Input: path}
+			ret = append(ret, byMac[mac])
+		}
+	}
+	for mac, path := range imus {
+		if n, ok := byMac[mac]; ok {
+			n.Imu = path
+		}
+	}
+	return ret, nil
+}
+
+type evdevJoycon struct {
+	side JoyConSide
+	mac  string
+
+	input, imu *evdev.Device
+
+	subs   broadcaster
+	queued []Event // events waiting for `flush`, guarded by `mu`
+
+	mu sync.RWMutex
+
+	closed bool
+
+	stickMin, stickMax [2][2]int32 // [left, right][x, y]
+
+	keys        ButtonState // pressed keys
+	hat         [2]int32    // Pro Controller D-pad, -1/0/1 of x and y
+	currButtons ButtonState // keys and hat
+
+	rawStick  [2]Point
+	currStick [2]Ratio
+	hostRange [2]*StickRange
+	hostCalib [2]*CalibrationData
+	drift     [2]DriftEstimator
+
+	battery  int8
+	charging bool
+
+	accRes, gyroRes float64  // units per G, per degree/s
+	sample          [6]int32 // accelerometer xyz, gyroscope xyz, of the current sample
+	sampleTime      int64    // MSC_TIMESTAMP of the current sample, microseconds
+	prevSampleTime  int64    // 0 before the first sample
+
+	gyroOn    bool
+	gyroBegin GyroFrame
+	gyroClock time.Duration
+	fusion    *Madgwick
+	bias      BiasEstimator
+
+	stats statsTracker
+}
+
+// Open the nodes found by `FindEvdevNodes`, the IMU is optional.
+func NewEvdevJoycon(nodes *EvdevNodes) (Controller, error) {
+	input, e := evdev.Open(nodes.Input)
+	if e != nil {
+		return nil, e
+	}
+	jc := &evdevJoycon{
+		side:    nodes.Side,
+		mac:     nodes.Mac,
+		input:   input,
+		fusion:  NewMadgwick(),
+		battery: -1,
+	}
+
+	for i, axes := range evdevStickAxes {
+		for j, code := range axes {
+			info, e := input.AbsInfo(code)
+			if e != nil || info.Maximum <= info.Minimum {
+				continue // this side has no stick
+			}
+			jc.stickMin[i][j], jc.stickMax[i][j] = info.Minimum, info.Maximum
+		}
+	}
+
+	if nodes.Imu != "" {
+		if jc.imu, e = evdev.Open(nodes.Imu); e != nil {
+			log.Warningf("%s: fail to open IMU %s: %s", jc.mac, nodes.Imu, e.Error())
+		} else {
+			acc, _ := jc.imu.AbsInfo(evdev.ABS_X)
+			gyro, _ := jc.imu.AbsInfo(evdev.ABS_RX)
+			jc.accRes, jc.gyroRes = float64(acc.Resolution), float64(gyro.Resolution)
+		}
+	}
+
+	go jc.readLoop(jc.input, jc.handleInput)
+	if jc.imu != nil {
+		go jc.readLoop(jc.imu, jc.handleImu)
+	}
+	go jc.pollBattery()
+
+	return jc, nil
+}
+
+func (jc *evdevJoycon) Mac() string      { return jc.mac }
+func (jc *evdevJoycon) Side() JoyConSide { return jc.side }
+
+func (jc *evdevJoycon) Subscribe(lsn EventListener) RemoveListenerFn {
+	return jc.subs.subscribe(lsn)
+}
+func (jc *evdevJoycon) SubscribeChan(buffer int, drop DropPolicy) (<-chan Event, RemoveListenerFn) {
+	return jc.subs.subscribeChan(buffer, drop)
+}
+
+// save the event, it's sent by `flush` after unlocking, must hold `mu`
+func (jc *evdevJoycon) queue(ev Event) {
+	ev.Source = jc
+	jc.queued = append(jc.queued, ev)
+}
+
+// send the queued events, must not hold `mu`
+func (jc *evdevJoycon) flush() {
+	jc.mu.Lock()
+	events := jc.queued
+	jc.queued = nil
+	jc.mu.Unlock()
+
+	for i := range events {
+		jc.subs.emit(&events[i])
+	}
+}
+
+func (jc *evdevJoycon) Disconnect() {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	if jc.closed {
+		return
+	}
+	jc.closed = true
+	jc.input.Close()
+	if jc.imu != nil {
+		jc.imu.Close()
+	}
+}
+
+// The driver owns the connection, disconnect it in the system Bluetooth manager instead
+func (jc *evdevJoycon) ShutdownBT() error { return ErrEvdevUnsupported }
+
+// Read the events of one node, `handle` is called for each group ending with SYN_REPORT.
+func (jc *evdevJoycon) readLoop(dev *evdev.Device, handle func([]evdev.Event)) {
+	var group []evdev.Event
+	for {
+		events, e := dev.Read()
+		if e != nil {
+			jc.mu.RLock()
+			closed := jc.closed
+			jc.mu.RUnlock()
+			if !closed {
+				jc.subs.emit(&Event{Type: Event_ReadWriteError, Source: jc, Err: e})
+			}
+			return
+		}
+		for _, ev := range events {
+			if ev.Type == evdev.EV_SYN && ev.Code == evdev.SYN_REPORT {
+				handle(group)
+				group = group[:0]
+				continue
+			}
+			group = append(group, ev)
+		}
+	}
+}
+
+func (jc *evdevJoycon) handleInput(events []evdev.Event) {
+	defer jc.flush()
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	stickMoved := false
+
+	for _, ev := range events {
+		switch ev.Type {
+		case evdev.EV_KEY:
+			id, ok := evdevButtons[jc.side][ev.Code]
+			if !ok {
+				continue
+			}
+			if ev.Value != 0 {
+				jc.keys.Set(id)
+			} else {
+				jc.keys.Clear(id)
+			}
+		case evdev.EV_ABS:
+			switch ev.Code {
+			case evdev.ABS_HAT0X:
+				jc.hat[0] = ev.Value
+			case evdev.ABS_HAT0Y:
+				jc.hat[1] = ev.Value
+			}
+			for i, axes := range evdevStickAxes {
+				for j, code := range axes {
+					if ev.Code == code && jc.stickMax[i][j] > jc.stickMin[i][j] {
+						jc.setRawStick(i, j, ev.Value)
+						stickMoved = true
+					}
+				}
+			}
+		}
+	}
+
+	// older drivers report the D-pad of the Pro Controller as a hat
+	jc.updateButtons(hatButtons(jc.keys, jc.hat))
+
+	if stickMoved {
+		var curr [2]Ratio
+		if jc.side.IsLeft() {
+			curr[0] = jc.adjustStick(0)
+		}
+		if jc.side.IsRight() {
+			curr[1] = jc.adjustStick(1)
+		}
+		jc.updateSticks(curr)
+	}
+}
+
+// add the D-pad buttons of the hat position, y grows downward
+func hatButtons(buttons ButtonState, hat [2]int32) ButtonState {
+	dirs := []struct {
+		id      ButtonID
+		pressed bool
+	}{
+		{Button_L_Left, hat[0] < 0}, {Button_L_Right, hat[0] > 0},
+		{Button_L_Up, hat[1] < 0}, {Button_L_Down, hat[1] > 0},
+	}
+	for _, d := range dirs {
+		if d.pressed {
+			buttons.Set(d.id)
+		}
+	}
+	return buttons
+}
+
+// scale the driver value to 12 bits, y is flipped as it grows downward on evdev
+func (jc *evdevJoycon) setRawStick(i, j int, value int32) {
+	min, max := jc.stickMin[i][j], jc.stickMax[i][j]
+	v := uint16(int64(value-min) * (2*evdevStickCenter - 1) / int64(max-min))
+	if j == 0 {
+		jc.rawStick[i].X = v
+	} else {
+		jc.rawStick[i].Y = 2*evdevStickCenter - 1 - v
+	}
+}
+
+func (jc *evdevJoycon) updateButtons(curr ButtonState) {
+	prev := jc.currButtons
+	jc.currButtons = curr
+
+	down := prev.DownMask(curr)
+	up := prev.UpMask(curr)
+	if !down.IsZero() || !up.IsZero() {
+		jc.queue(Event{Type: Event_Button, Down: down, Up: up, Buttons: curr})
+	}
+}
+
+func (jc *evdevJoycon) updateSticks(curr [2]Ratio) {
+	prev := jc.currStick
+	jc.currStick = curr
+
+	for i, side := range []JoyConSide{SideLeft, SideRight} {
+		if !curr[i].AtNeutral() || !prev[i].AtNeutral() {
+			jc.queue(Event{Type: Event_Stick, Side: side, Stick: curr[i], PrevStick: prev[i]})
+		}
+	}
+}
+
+// same steps as the hidraw one: calibration, drift, measured range, response
+func (jc *evdevJoycon) adjustStick(i int) Ratio {
+	calib := &evdevStickCalib
+	if jc.hostCalib[i] != nil {
+		calib = jc.hostCalib[i]
+	}
+	ratio := calib.Adjust(&jc.rawStick[i])
+
+	busy := !jc.currButtons.IsZero()
+	if jc.drift[i].Correct(&ratio, busy, time.Now()) {
+		side := JoyConSide(SideLeft)
+		if i == 1 {
+			side = SideRight
+		}
+		jc.queue(Event{Type: Event_StickDrift, Side: side, Stick: jc.drift[i].Offset()})
+	}
+	if jc.hostRange[i] != nil {
+		jc.hostRange[i].Apply(&ratio)
+	}
+	if resp := StickResponses[i]; resp != nil {
+		resp.Apply(&ratio)
+	}
+	return ratio
+}
+
+// The driver reports each IMU sample as a group, with its timestamp
+func (jc *evdevJoycon) handleImu(events []evdev.Event) {
+	recv := time.Now()
+
+	defer jc.flush()
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	for _, ev := range events {
+		switch {
+		case ev.Type == evdev.EV_ABS && ev.Code <= evdev.ABS_RZ:
+			jc.sample[ev.Code] = ev.Value
+		case ev.Type == evdev.EV_MSC && ev.Code == evdev.MSC_TIMESTAMP:
+			jc.sampleTime = int64(uint32(ev.Value))
+		}
+	}
+	if jc.accRes == 0 || jc.gyroRes == 0 {
+		return
+	}
+
+	interval := SampleInterval
+	if jc.prevSampleTime != 0 {
+		us := jc.sampleTime - jc.prevSampleTime
+		if us < 0 { // the 32 bits timestamp wraps around
+			us += math.MaxUint32 + 1
+		}
+		interval = time.Duration(us) * time.Microsecond
+	}
+	jc.prevSampleTime = jc.sampleTime
+
+	// each sample counts as a report of `reportTicks`, so the lost ones are counted
+	timer := byte(jc.sampleTime / int64(SampleInterval/time.Microsecond) * reportTicks)
+	jc.stats.record(timer, recv, time.Since(recv))
+
+	if !jc.gyroOn || interval == 0 {
+		return
+	}
+	if interval > maxSampleInterval {
+		interval = maxSampleInterval
+	}
+	jc.gyroClock += interval
+
+	var f GyroFrame
+	f.Accel = Vector3{
+		X: float64(jc.sample[0]) / jc.accRes,
+		Y: float64(jc.sample[1]) / jc.accRes,
+		Z: float64(jc.sample[2]) / jc.accRes,
+	}
+	f.Rotation = AngularVelocity{
+		Roll:  float64(jc.sample[3]) / jc.gyroRes,
+		Pitch: float64(jc.sample[4]) / jc.gyroRes,
+		Yaw:   float64(jc.sample[5]) / jc.gyroRes,
+	}
+	// the raw fields in the nominal sensitivity of the hidraw reports
+	f.Gyro3D = Gyro3D{
+		X: nominal(f.Accel.X / 0.000244), Y: nominal(f.Accel.Y / 0.000244), Z: nominal(f.Accel.Z / 0.000244),
+	}
+	f.Acceleration = Acceleration{
+		Roll: nominal(f.Rotation.Roll / 0.070), Pitch: nominal(f.Rotation.Pitch / 0.070), Yaw: nominal(f.Rotation.Yaw / 0.070),
+	}
+	if jc.gyroBegin == GyroFrame_Nil {
+		jc.gyroBegin = f
+	}
+	f.Gyro3D = f.Adjust(&jc.gyroBegin.Gyro3D)
+
+	f.Timestamp = jc.gyroClock
+	f.Interval = interval
+
+	jc.bias.Compensate(&f.Rotation, &f.Accel, f.Interval)
+	jc.fusion.Update(&f.Rotation, &f.Accel, f.Interval)
+	f.Orientation = jc.fusion.Orientation()
+
+	jc.queue(Event{Type: Event_Gyro, Side: jc.side, Gyro: f})
+}
+
+func nominal(v float64) int16 {
+	return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, v)))
+}
+
+// Read the battery from the power supply of the driver, every `evdevBatteryInterval`
+func (jc *evdevJoycon) pollBattery() {
+	ticker := time.NewTicker(evdevBatteryInterval)
+	defer ticker.Stop()
+
+	for {
+		jc.mu.RLock()
+		closed := jc.closed
+		jc.mu.RUnlock()
+		if closed {
+			return
+		}
+
+		if level, charging, e := jc.readBattery(); e == nil {
+			jc.mu.Lock()
+			changed := level != jc.battery || charging != jc.charging
+			jc.battery, jc.charging = level, charging
+			if changed {
+				jc.queue(Event{Type: Event_Battery, Battery: level, Charging: charging})
+			}
+			jc.mu.Unlock()
+			jc.flush()
+		}
+		<-ticker.C
+	}
+}
+
+// e.g. /sys/class/input/event5/device/device/power_supply/nintendo_switch_controller_battery_<mac>
+func (jc *evdevJoycon) readBattery() (int8, bool, error) {
+	dirs, _ := filepath.Glob(filepath.Join(jc.input.SysPath(), "device", "power_supply", "*"))
+	if len(dirs) == 0 {
+		return 0, false, os.ErrNotExist
+	}
+	b, e := os.ReadFile(filepath.Join(dirs[0], "capacity_level"))
+	if e != nil {
+		return 0, false, e
+	}
+	level, ok := evdevBatteryLevels[strings.TrimSpace(string(b))]
+	if !ok {
+		return 0, false, errors.New("unknown capacity level: " + string(b))
+	}
+	status, _ := os.ReadFile(filepath.Join(dirs[0], "status"))
+	return level, strings.TrimSpace(string(status)) == "Charging", nil
+}
+
+// -1 before it's read
+func (jc *evdevJoycon) Battery() (int8, bool) {
+	jc.mu.RLock()
+	defer jc.mu.RUnlock()
+
+	return jc.battery, jc.charging
+}
+
+// The IMU keeps reporting, it's only about whether the gyro events are sent
+func (jc *evdevJoycon) EnableGyro(enable bool) error {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	if jc.imu == nil && enable {
+		return errors.New("no IMU node")
+	}
+	jc.gyroOn = enable
+	jc.gyroBegin = GyroFrame_Nil
+	jc.gyroClock = 0
+	jc.fusion.Reset()
+	return nil
+}
+
+func (jc *evdevJoycon) GyroBias() AngularVelocity {
+	jc.mu.RLock()
+	defer jc.mu.RUnlock()
+
+	return jc.bias.Bias()
+}
+func (jc *evdevJoycon) SetGyroBias(bias AngularVelocity) {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	jc.bias.SetBias(bias)
+}
+
+// Rumble and lights belong to the driver
+func (jc *evdevJoycon) Rumble(*RumbleFrequency) error { return ErrEvdevUnsupported }
+func (jc *evdevJoycon) PlayRumble(RumblePattern) {
+	log.Debugf("%s: rumble %s", jc.mac, ErrEvdevUnsupported.Error())
+}
+func (jc *evdevJoycon) SetLights(byte) error          { return ErrEvdevUnsupported }
+func (jc *evdevJoycon) SetHomeLight(*HomeLight) error { return ErrEvdevUnsupported }
+
+// The driver calibrates them
+func (jc *evdevJoycon) CalibrateStick() error { return nil }
+func (jc *evdevJoycon) CalibrateIMU() error   { return nil }
+
+func (jc *evdevJoycon) RawStick() [2]Point {
+	jc.mu.RLock()
+	defer jc.mu.RUnlock()
+
+	return jc.rawStick
+}
+
+func (jc *evdevJoycon) SetStickRanges(ranges [2]*StickRange) {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	for i, r := range ranges {
+		jc.hostRange[i], jc.hostCalib[i] = r, nil
+		jc.drift[i] = DriftEstimator{}
+		if r != nil {
+			c := r.Calibration()
+			jc.hostCalib[i] = &c
+		}
+	}
+}
+
+func (jc *evdevJoycon) StickDrift() [2]Ratio {
+	jc.mu.RLock()
+	defer jc.mu.RUnlock()
+
+	return [2]Ratio{jc.drift[0].Offset(), jc.drift[1].Offset()}
+}
+
+func (jc *evdevJoycon) Stats() ReportStats {
+	return jc.stats.snapshot()
+}
+func (jc *evdevJoycon) ResetStats() {
+	jc.stats.reset()
+}
+
+func (jc *evdevJoycon) Test() {
+	log.Infof("%s: evdev %s, IMU: %v", jc.mac, jc.input.Path(), jc.imu != nil)
+}
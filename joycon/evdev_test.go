@@ -0,0 +1,102 @@
+package joycon
+
+import (
+	"testing"
+
+	"github.com/aj3423/joy-typing/evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEvdevJoycon(side JoyConSide, p *probe) *evdevJoycon {
+	jc := &evdevJoycon{side: side, fusion: NewMadgwick(), accRes: 4096, gyroRes: 14}
+	for i := range jc.stickMin {
+		for j := range jc.stickMin[i] {
+			jc.stickMin[i][j], jc.stickMax[i][j] = -32767, 32767
+		}
+	}
+	jc.Subscribe(p)
+	return jc
+}
+
+func key(code uint16, down bool) evdev.Event {
+	ev := evdev.Event{Type: evdev.EV_KEY, Code: code}
+	if down {
+		ev.Value = 1
+	}
+	return ev
+}
+
+func abs(code uint16, v int32) evdev.Event {
+	return evdev.Event{Type: evdev.EV_ABS, Code: code, Value: v}
+}
+
+func TestEvdevButtons(t *testing.T) {
+	p := &probe{}
+	jc := newTestEvdevJoycon(SideRight, p)
+
+	jc.handleInput([]evdev.Event{key(evdev.BTN_EAST, true)})
+	jc.handleInput([]evdev.Event{key(evdev.BTN_TL, true)}) // SL of the right one
+	jc.handleInput([]evdev.Event{key(evdev.BTN_EAST, false), key(evdev.BTN_TL, false)})
+
+	assert.Equal(t, 3, len(p.buttons))
+	assert.True(t, p.buttons[0].Has(Button_R_A))
+	assert.True(t, p.buttons[1].Has(Button_R_SL))
+	assert.True(t, p.buttons[2].IsZero())
+	assert.True(t, jc.currButtons.IsZero())
+}
+
+func TestEvdevHat(t *testing.T) {
+	p := &probe{}
+	jc := newTestEvdevJoycon(SideBoth, p)
+
+	jc.handleInput([]evdev.Event{abs(evdev.ABS_HAT0X, 1), abs(evdev.ABS_HAT0Y, -1)})
+	assert.True(t, jc.currButtons.Has(Button_L_Right))
+	assert.True(t, jc.currButtons.Has(Button_L_Up))
+
+	jc.handleInput([]evdev.Event{abs(evdev.ABS_HAT0X, 0), abs(evdev.ABS_HAT0Y, 0)})
+	assert.True(t, jc.currButtons.IsZero())
+}
+
+func TestEvdevStick(t *testing.T) {
+	p := &probe{}
+	jc := newTestEvdevJoycon(SideRight, p)
+
+	// up and right, y grows downward on evdev
+	jc.handleInput([]evdev.Event{abs(evdev.ABS_RX, 32767), abs(evdev.ABS_RY, -32767)})
+	assert.Equal(t, 1, len(p.sticks))
+	assert.InDelta(t, 1, p.sticks[0].X, 0.01)
+	assert.InDelta(t, 1, p.sticks[0].Y, 0.01)
+
+	jc.handleInput([]evdev.Event{abs(evdev.ABS_RX, 0), abs(evdev.ABS_RY, 0)})
+	assert.Equal(t, 2, len(p.sticks))
+	assert.True(t, p.sticks[1].AtNeutral())
+}
+
+func TestEvdevImu(t *testing.T) {
+	p := &probe{}
+	jc := newTestEvdevJoycon(SideRight, p)
+	assert.NotNil(t, jc.EnableGyro(true)) // no IMU node
+	jc.imu = &evdev.Device{}
+
+	sample := func(us int32) []evdev.Event {
+		return []evdev.Event{
+			abs(evdev.ABS_Z, 4096), abs(evdev.ABS_RZ, 14*100),
+			{Type: evdev.EV_MSC, Code: evdev.MSC_TIMESTAMP, Value: us},
+		}
+	}
+
+	jc.handleImu(sample(1000)) // gyro off, only counted
+	assert.Equal(t, 0, len(p.gyros))
+
+	assert.Nil(t, jc.EnableGyro(true))
+	jc.handleImu(sample(6000))
+	jc.handleImu(sample(11000))
+
+	assert.Equal(t, 2, len(p.gyros))
+	f := p.gyros[1]
+	assert.InDelta(t, 1, f.Accel.Z, 1e-9)
+	assert.Equal(t, SampleInterval, f.Interval)
+	assert.Equal(t, 2*SampleInterval, f.Timestamp)
+	assert.Equal(t, uint64(3), jc.Stats().Reports)
+	assert.Equal(t, uint64(0), jc.Stats().Lost)
+}
@@ -0,0 +1,185 @@
+package joycon
+
+import "sync"
+
+type EventType int
+
+const (
+	Event_ReadWriteError EventType = iota
+	Event_Button
+	Event_Stick
+	Event_StickCalib
+	Event_StickDrift
+	Event_Gyro
+	Event_Battery
+)
+
+// A copy of everything about an event, so it can be buffered.
+// Only the fields of its `Type` are set.
+type Event struct {
+	Type   EventType
+	Source Controller
+	Side   JoyConSide // which stick or IMU
+
+	Err error
+
+	Down, Up, Buttons ButtonState
+
+	Stick, PrevStick Ratio // `Stick` is the offset of StickDrift
+	Calib            [2]CalibrationData
+
+	Gyro GyroFrame
+
+	Battery  int8
+	Charging bool
+}
+
+// Pass the event to the listener, as if it's called by the controller
+func (ev *Event) Dispatch(lsn EventListener) {
+	switch ev.Type {
+	case Event_ReadWriteError:
+		lsn.OnReadWriteError(ev.Source, ev.Err)
+	case Event_Button:
+		lsn.OnButton(ev.Source, &ev.Down, &ev.Up, &ev.Buttons)
+	case Event_Stick:
+		lsn.OnStick(ev.Source, ev.Side, &ev.Stick, &ev.PrevStick)
+	case Event_StickCalib:
+		lsn.OnStickCalib(ev.Source, &ev.Calib)
+	case Event_StickDrift:
+		lsn.OnStickDrift(ev.Source, ev.Side, &ev.Stick)
+	case Event_Gyro:
+		lsn.OnGyro(ev.Source, ev.Side, &ev.Gyro)
+	case Event_Battery:
+		lsn.OnBattery(ev.Source, ev.Battery, ev.Charging)
+	}
+}
+
+// the high rate ones, a missing one is replaced by the next very soon
+func (ev *Event) droppable() bool {
+	return ev.Type == Event_Stick || ev.Type == Event_Gyro
+}
+
+// What to do with a stick/gyro event when the channel is full,
+// other events always wait for room.
+type DropPolicy int
+
+const (
+	DropNewest DropPolicy = iota // discard the new one
+	DropOldest                   // discard the oldest buffered one to make room
+	DropNone                     // wait for room, it stalls the read loop
+)
+
+type subscriber struct {
+	lsn EventListener // called directly, nil if it's a channel subscriber
+
+	ch   chan Event
+	drop DropPolicy
+	done chan struct{} // closed when unsubscribed, stops waiting for room
+	mu   sync.RWMutex  // senders hold RLock, so `ch` isn't closed while sending
+}
+
+func (s *subscriber) deliver(ev *Event) {
+	if s.lsn != nil {
+		copied := *ev // it may be modified by the listener
+		copied.Dispatch(s.lsn)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	if ev.droppable() && s.drop != DropNone {
+		select {
+		case s.ch <- *ev:
+			return
+		default: // full
+		}
+		if s.drop == DropNewest {
+			return
+		}
+		select { // DropOldest
+		case <-s.ch:
+		default:
+		}
+		select {
+		case s.ch <- *ev:
+		default: // taken by another sender, give up
+		}
+		return
+	}
+
+	select {
+	case s.ch <- *ev:
+	case <-s.done:
+	}
+}
+
+func (s *subscriber) close() {
+	if s.ch == nil {
+		return
+	}
+	close(s.done) // unblock the waiting senders
+	s.mu.Lock()
+	close(s.ch)
+	s.mu.Unlock()
+}
+
+// Delivers events to all subscribers, without holding any lock of the controller
+type broadcaster struct {
+	mu   sync.Mutex
+	subs []*subscriber
+}
+
+func (b *broadcaster) add(s *subscriber) RemoveListenerFn {
+	b.mu.Lock()
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			for i, sub := range b.subs {
+				if sub == s {
+					b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+					break
+				}
+			}
+			b.mu.Unlock()
+
+			s.close()
+		})
+	}
+}
+
+// The listener is called from the read loop, keep it quick or use `subscribeChan`.
+// It may still get an event or two right after removed.
+func (b *broadcaster) subscribe(lsn EventListener) RemoveListenerFn {
+	return b.add(&subscriber{lsn: lsn})
+}
+
+// The channel is closed when removed.
+func (b *broadcaster) subscribeChan(buffer int, drop DropPolicy) (<-chan Event, RemoveListenerFn) {
+	s := &subscriber{
+		ch:   make(chan Event, buffer),
+		drop: drop,
+		done: make(chan struct{}),
+	}
+	return s.ch, b.add(s)
+}
+
+func (b *broadcaster) emit(ev *Event) {
+	b.mu.Lock()
+	subs := b.subs // `add` and remove never modify it in place
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.deliver(ev)
+	}
+}
@@ -0,0 +1,106 @@
+package joycon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultipleSubscribers(t *testing.T) {
+	p1, p2 := &probe{}, &probe{}
+	jc := newTestJoycon(SideRight, p1)
+	unbind := jc.Subscribe(p2)
+
+	jc.decodeButton(newReport(ButtonState{byte(Button_R_A)}, 0, 0))
+	unbind()
+	jc.decodeButton(newReport(ButtonState{}, 0, 0))
+
+	assert.Equal(t, 2, len(p1.buttons))
+	assert.Equal(t, 1, len(p2.buttons))
+}
+
+// calls back into the controller, it deadlocks if called with the lock held
+type reentrantProbe struct {
+	probe
+	jc Controller
+}
+
+func (p *reentrantProbe) OnStick(jc Controller, t JoyConSide, curr, prev *Ratio) {
+	p.jc.RawStick()
+	p.jc.SetStickRanges([2]*StickRange{})
+	p.probe.OnStick(jc, t, curr, prev)
+}
+
+func TestListenerWithoutLock(t *testing.T) {
+	p := &reentrantProbe{}
+	jc := newTestJoycon(SideRight, nil)
+	p.jc = jc
+	jc.Subscribe(p)
+	jc.handleSubcommandReply(newCalibReply())
+
+	done := make(chan struct{})
+	go func() {
+		jc.decodePacket(newReport(ButtonState{}, 0xC00, 0x800))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("deadlock")
+	}
+	assert.Equal(t, 1, len(p.sticks))
+}
+
+func TestSubscribeChan(t *testing.T) {
+	stick := func(x float64) *Event {
+		return &Event{Type: Event_Stick, Stick: Ratio{X: x}}
+	}
+
+	// drop the newest stick event
+	b := &broadcaster{}
+	ch, _ := b.subscribeChan(2, DropNewest)
+	for i := 1; i <= 3; i++ {
+		b.emit(stick(float64(i)))
+	}
+	assert.Equal(t, 1.0, (<-ch).Stick.X)
+	assert.Equal(t, 2.0, (<-ch).Stick.X)
+
+	// drop the oldest
+	b = &broadcaster{}
+	ch, unbind := b.subscribeChan(2, DropOldest)
+	for i := 1; i <= 3; i++ {
+		b.emit(stick(float64(i)))
+	}
+	assert.Equal(t, 2.0, (<-ch).Stick.X)
+	assert.Equal(t, 3.0, (<-ch).Stick.X)
+
+	// buttons are never dropped, it waits for room
+	b.emit(stick(4))
+	b.emit(stick(5))
+	sent := make(chan struct{})
+	go func() {
+		b.emit(&Event{Type: Event_Button})
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("button event dropped")
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-ch
+	<-sent
+	<-ch
+	assert.Equal(t, Event_Button, (<-ch).Type)
+
+	// the channel is closed after unbound, even with a sender waiting
+	b.emit(stick(6))
+	b.emit(stick(7))
+	go b.emit(&Event{Type: Event_Button})
+	time.Sleep(10 * time.Millisecond)
+	unbind()
+	unbind() // twice is fine
+	for range ch {
+	}
+	b.emit(stick(8)) // no subscriber
+}
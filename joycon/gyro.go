@@ -1,5 +1,11 @@
 package joycon
 
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
 type Gyro3D struct {
 	X, Y, Z int16
 }
@@ -16,6 +22,14 @@ type Acceleration struct {
 	Roll, Pitch, Yaw int16
 }
 
+// Calibrated values in physical units
+type Vector3 struct {
+	X, Y, Z float64
+}
+type AngularVelocity struct {
+	Roll, Pitch, Yaw float64
+}
+
 // from: https://github.com/dekuNukem/Nintendo_Switch_Reverse_Engineering/blob/master/imu_sensor_notes.md
 // The 6-Axis data is repeated 3 times. On Joy-con with a 15ms packet push,
 // this is translated to 5ms difference sampling.
@@ -24,6 +38,76 @@ type Acceleration struct {
 type GyroFrame struct {
 	Gyro3D       // absolute value
 	Acceleration // reletive value
+
+	// calibrated from the raw values above
+	Accel    Vector3         // acceleration in G, from `Gyro3D`
+	Rotation AngularVelocity // deg/s, from `Acceleration`
+
+	// Derived from the report timer, not the receiving time,
+	// so it's not affected by the Bluetooth jitter.
+	Timestamp time.Duration // since the gyro is enabled
+	Interval  time.Duration // since the previous sample
+
+	// fused from gyro and accelerometer
+	Orientation Orientation
 }
 
 var GyroFrame_Nil GyroFrame
+
+const (
+	// Each tick of the report timer(byte 1 of input report),
+	// it's also the time between 2 IMU samples
+	SampleInterval = 5 * time.Millisecond
+
+	// Limit the interval after lost packets,
+	// otherwise the cursor jumps after a long Bluetooth stall.
+	maxSampleInterval = 50 * time.Millisecond
+)
+
+// Sensor offsets and sensitivity, read from SPI flash
+// ref: https://github.com/dekuNukem/Nintendo_Switch_Reverse_Engineering/blob/master/spi_flash_notes.md#6-axis-horizontal-offsets
+type ImuCalibration struct {
+	accOrigin, accCoeff   [3]int16
+	gyroOrigin, gyroCoeff [3]int16
+}
+
+var EmptyImuCalibration = ImuCalibration{}
+
+func (c *ImuCalibration) Parse(b []byte) {
+	for i := 0; i < 3; i++ {
+		c.accOrigin[i] = int16(binary.LittleEndian.Uint16(b[0+2*i:]))
+		c.accCoeff[i] = int16(binary.LittleEndian.Uint16(b[6+2*i:]))
+		c.gyroOrigin[i] = int16(binary.LittleEndian.Uint16(b[12+2*i:]))
+		c.gyroCoeff[i] = int16(binary.LittleEndian.Uint16(b[18+2*i:]))
+	}
+}
+
+func (c ImuCalibration) String() string {
+	return fmt.Sprintf("acc: %v/%v gyro: %v/%v",
+		c.accOrigin, c.accCoeff, c.gyroOrigin, c.gyroCoeff)
+}
+
+// Fill the calibrated values of the frame,
+// the nominal sensitivity is used if not calibrated.
+func (c *ImuCalibration) Apply(f *GyroFrame) {
+	acc := [3]int16{f.X, f.Y, f.Z}
+	gyro := [3]int16{f.Roll, f.Pitch, f.Yaw}
+	var accG, gyroDps [3]float64
+
+	for i := 0; i < 3; i++ {
+		if c.accCoeff[i] != c.accOrigin[i] {
+			accG[i] = float64(acc[i]) * 4 / float64(int(c.accCoeff[i])-int(c.accOrigin[i]))
+		} else {
+			accG[i] = float64(acc[i]) * 0.000244 // +-8G
+		}
+
+		if c.gyroCoeff[i] != c.gyroOrigin[i] {
+			gyroDps[i] = float64(int(gyro[i])-int(c.gyroOrigin[i])) *
+				936 / float64(int(c.gyroCoeff[i])-int(c.gyroOrigin[i]))
+		} else {
+			gyroDps[i] = float64(gyro[i]) * 0.070 // +-2000dps
+		}
+	}
+	f.Accel = Vector3{accG[0], accG[1], accG[2]}
+	f.Rotation = AngularVelocity{gyroDps[0], gyroDps[1], gyroDps[2]}
+}
@@ -6,10 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
-	"github.com/sstallion/go-hid"
 )
 
 const (
@@ -24,13 +24,14 @@ const (
 )
 
 type joycon struct {
-	hidDev *hid.Device
+	transport Transport
 
 	packetId byte // // Increment by 1 for each packet sent. It loops in 0x0 - 0xF range.
 
 	side JoyConSide
 
-	listener EventListener
+	subs   broadcaster
+	queued []Event // events waiting for `flush`, guarded by `mu`
 
 	mac string
 
@@ -40,71 +41,169 @@ type joycon struct {
 	prevButtons ButtonState // button state for previous frame
 	currButtons ButtonState // button state for current frame
 
-	prevStick  [2]Ratio           // [left, right][x, y]
-	currStick  [2]Ratio           // [left, right][x, y]
-	stickCalib [2]CalibrationData // [left, right]
+	prevStick  [2]Ratio            // [left, right][x, y]
+	currStick  [2]Ratio            // [left, right][x, y]
+	rawStick   [2]Point            // [left, right], before calibration
+	stickCalib [2]CalibrationData  // [left, right]
+	hostRange  [2]*StickRange      // measured on the host, preferred over `stickCalib`
+	hostCalib  [2]*CalibrationData // calibration of `hostRange`
+	drift      [2]DriftEstimator   // re-centers a drifting stick
 
 	gyroOn    bool
 	gyroBegin GyroFrame // the initial state when start rotating
 	gyro      [3]GyroFrame
+
+	gyroTimer      byte          // report timer of previous IMU report
+	gyroTimerValid bool          // false before the first IMU report
+	gyroClock      time.Duration // time of the latest sample, since gyro enabled
+	fusion         *Madgwick     // orientation estimator
+	bias           BiasEstimator // gyro drift compensation
+
+	imuCalib     ImuCalibration
+	imuUserCalib bool // user calibration is preferred over factory one
+
+	rawReads atomic.Int32 // pending `SPIRead()`, their replies don't change calibration
+
+	rumbler rumblePlayer
+
+	lights   byte // desired player lights, kept by `keepLights`
+	lightsOn bool // false if never set
+
+	muRequest sync.Mutex    // one subcommand at a time
+	muPending sync.Mutex    // guards `pending`, it's accessed by readLoop
+	pending   *pendingReply // the subcommand waiting for reply
+
+	ready     chan struct{} // closed when the first report arrives
+	readyOnce sync.Once
+
+	stats statsTracker
+
+	reportMode byte         // the input report mode entered, 0 before that
+	homeLight  *HomeLight   // the last HOME light set, nil if never
+	lastReport atomic.Int64 // UnixNano of the latest report, for the watchdog
 }
 
 func NewJoycon(
-	hidDev *hid.Device,
+	transport Transport,
 	side JoyConSide,
 	mac string,
+) Controller {
+	return RestoreJoycon(transport, side, mac, nil)
+}
+
+// Same as `NewJoycon`, but restores the state saved before it disconnected,
+// nil state for a new one.
+func RestoreJoycon(
+	transport Transport,
+	side JoyConSide,
+	mac string,
+	state *State,
 ) Controller {
 	jc := &joycon{
-		hidDev: hidDev,
-		side:   side,
-		mac:    mac,
+		transport: transport,
+		side:      side,
+		mac:       mac,
+		fusion:    NewMadgwick(),
+		ready:     make(chan struct{}),
 	}
+	jc.lastReport.Store(time.Now().UnixNano())
 
 	go jc.readLoop()
-
-	go func() {
-		// switch runtime.GOOS {
-		// case "linux": // do nothing, linux auto enters StandardFullMode, no idea why.
-		// }
-		// Sometimes it never get the response if the calibrating packet is sent too quick(right after attached)
-		// It may even cause the CPU goes to 100% and system freezes to death.
-		// Don't call `SPIRead()` too early, even a 500ms delay is too short.
-		// 2 seconds seems ok so far, never freezes yet and always calibrates successfully.
-		time.Sleep(2 * time.Second)
-
-		jc.enterMode(StandardFull)
-
-		time.Sleep(200 * time.Millisecond)
-		for try := 0; try < 3; try++ {
-			if jc.isCalibrated() {
-				break
-			}
-			jc.CalibrateStick()
-			time.Sleep(time.Second)
-		}
-	}()
+	go jc.setup(state)
+	go jc.keepLights()
+	go jc.watchdog()
 
 	return jc
 }
+
+// Sometimes it never get the response if the calibrating packet is sent too quick(right after attached)
+// It may even cause the CPU goes to 100% and system freezes to death.
+// Don't send anything too early, even a 500ms delay is too short.
+// So wait until it starts reporting and then `setupSettle`,
+// after that each step waits for the reply of the previous one.
+// The SPI reads are skipped if the calibration is restored from `state`.
+func (jc *joycon) setup(state *State) {
+	select {
+	case <-jc.ready:
+	case <-time.After(setupTimeout):
+		log.Warningf("%s: no report in %s, setup anyway", jc.Mac(), setupTimeout)
+	}
+	time.Sleep(setupSettle)
+
+	// switch runtime.GOOS {
+	// case "linux": // do nothing, linux auto enters StandardFullMode, no idea why.
+	// }
+	mode := StandardFull
+	if state != nil && state.ReportMode != 0 {
+		mode = state.ReportMode
+	}
+	if e := jc.enterMode(mode); e != nil {
+		log.Errorf("%s: fail to enter full mode: %s", jc.Mac(), e.Error())
+		return
+	}
+	if state != nil {
+		jc.restore(state)
+		return
+	}
+	if e := jc.CalibrateStick(); e != nil {
+		log.Errorf("%s: fail to calibrate stick: %s", jc.Mac(), e.Error())
+	}
+	if e := jc.CalibrateIMU(); e != nil {
+		log.Errorf("%s: fail to calibrate IMU: %s", jc.Mac(), e.Error())
+	}
+}
+
+// Calibration of both sticks are read at once,
+// it's the same for Joy-Cons and the Pro Controller.
+// The user calibration overrides the factory one if it exists,
+// both are overridden by `SetStickRanges()`.
 func (jc *joycon) CalibrateStick() error {
-	return jc.SPIRead(factoryStickCalibStart, factoryStickCalibLen)
-	// time.Sleep(100 * time.Millisecond)
-	// jc.SPIRead(userStickCalibStart, userStickCalibLen)
+	if _, e := jc.spiRead(factoryStickCalibStart, factoryStickCalibLen); e != nil {
+		return e
+	}
+	_, e := jc.spiRead(userStickCalibStart, userStickCalibLen)
+	return e
 }
 
-func (jc *joycon) SetListener(lsn EventListener) RemoveListenerFn {
-	jc.mu.Lock()
-	defer jc.mu.Unlock()
+// Read both factory and user IMU calibration, the user one is used if it exists.
+func (jc *joycon) CalibrateIMU() error {
+	if _, e := jc.spiRead(factoryImuCalibStart, factoryImuCalibLen); e != nil {
+		return e
+	}
+	_, e := jc.spiRead(userImuCalibStart, userImuCalibLen)
+	return e
+}
 
-	jc.listener = lsn
+func (jc *joycon) Subscribe(lsn EventListener) RemoveListenerFn {
+	return jc.subs.subscribe(lsn)
+}
+func (jc *joycon) SubscribeChan(buffer int, drop DropPolicy) (<-chan Event, RemoveListenerFn) {
+	return jc.subs.subscribeChan(buffer, drop)
+}
 
-	return func() {
-		jc.mu.Lock()
-		defer jc.mu.Unlock()
+// save the event, it's sent by `flush` after unlocking, must hold `mu`
+func (jc *joycon) queue(ev Event) {
+	ev.Source = jc
+	jc.queued = append(jc.queued, ev)
+}
 
-		jc.listener = nil
+// send the queued events, must not hold `mu`
+func (jc *joycon) flush() {
+	jc.mu.Lock()
+	events := jc.queued
+	jc.queued = nil
+	jc.mu.Unlock()
+
+	for i := range events {
+		jc.subs.emit(&events[i])
 	}
 }
+
+// send an event right away, must not hold `mu`
+func (jc *joycon) emit(ev Event) {
+	ev.Source = jc
+	jc.subs.emit(&ev)
+}
 func (jc *joycon) Mac() string {
 	return jc.mac
 }
@@ -121,7 +220,13 @@ func (jc *joycon) Battery() (int8, bool) {
 func (jc *joycon) enterMode(mode byte) error {
 	sub := []byte{0x03, mode}
 
-	return jc.sendSubcommand(sub, nil)
+	_, e := jc.subcommand(sub)
+	if e == nil {
+		jc.mu.Lock()
+		jc.reportMode = mode
+		jc.mu.Unlock()
+	}
+	return e
 }
 func (jc *joycon) EnableGyro(enable bool) error {
 	sub := []byte{0x40, 0}
@@ -129,10 +234,31 @@ func (jc *joycon) EnableGyro(enable bool) error {
 		sub[1] = 1
 	}
 
+	jc.mu.Lock()
 	jc.gyroBegin = GyroFrame_Nil
 	jc.gyroOn = enable
+	jc.gyroTimerValid = false
+	jc.gyroClock = 0
+	jc.fusion.Reset()
+	jc.mu.Unlock()
 
-	return jc.sendSubcommand(sub, nil)
+	_, e := jc.subcommand(sub)
+	return e
+}
+
+// The gyro offset learned while resting, it's saved per controller
+// and restored next time, so it starts already compensated.
+func (jc *joycon) GyroBias() AngularVelocity {
+	jc.mu.RLock()
+	defer jc.mu.RUnlock()
+
+	return jc.bias.Bias()
+}
+func (jc *joycon) SetGyroBias(bias AngularVelocity) {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	jc.bias.SetBias(bias)
 }
 
 /*
@@ -141,29 +267,93 @@ func (jc *joycon) EnableGyro(enable bool) error {
 *   aaaa bbbb
 *        3210 - keep light on
 *   3210 - flash light
-* it works fine on Windows, but it only work for 1 frame on Linux even with "keep light on"
+* it works fine on Windows, but it only work for 1 frame on Linux even with "keep light on",
+* so it's re-sent every `LightRefresh` by `keepLights`
  */
-func (jc *joycon) SetLights(pattern byte) {
+func (jc *joycon) SetLights(pattern byte) error {
+	jc.mu.Lock()
+	jc.lights, jc.lightsOn = pattern, true
+	jc.mu.Unlock()
+
 	sub := []byte{0x30, byte(pattern)}
-	jc.sendSubcommand(sub, nil)
+	_, e := jc.subcommand(sub)
+	return e
+}
+
+// re-send the player lights until disconnected, see `needsRefresh()`
+func (jc *joycon) keepLights() {
+	if !refreshLights {
+		return
+	}
+	ticker := time.NewTicker(LightRefresh)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		jc.mu.RLock()
+		closed := jc.transport == nil
+		pattern, on := jc.lights, jc.lightsOn
+		jc.mu.RUnlock()
+
+		if closed {
+			return
+		}
+		if on && needsRefresh(pattern) {
+			// no need to wait for the reply
+			jc.sendSubcommand([]byte{0x30, pattern}, nil)
+		}
+	}
+}
+
+// The Left Joy-Con has no HOME button, it's ignored
+func (jc *joycon) SetHomeLight(h *HomeLight) error {
+	if jc.side == SideLeft {
+		return nil
+	}
+	data, e := h.Encode()
+	if e != nil {
+		return e
+	}
+	jc.mu.Lock()
+	jc.homeLight = h
+	jc.mu.Unlock()
+
+	_, e = jc.subcommand(append([]byte{0x38}, data...))
+	return e
+}
+
+// Perform an SPI read for information or dumping, returns the data read.
+// The calibration in it isn't applied, the controller state is unchanged.
+func (jc *joycon) SPIRead(addr uint32, length byte) ([]byte, error) {
+	jc.rawReads.Add(1)
+	defer jc.rawReads.Add(-1)
+
+	return jc.spiRead(addr, length)
 }
 
-// Perform an SPI read
-func (jc *joycon) SPIRead(addr uint32, len byte) error {
-	sub := []byte{0x10, 0, 0, 0, 0, len}
+// The reply is also handled by `handleSPIRead`, which applies the calibration.
+func (jc *joycon) spiRead(addr uint32, length byte) ([]byte, error) {
+	sub := []byte{0x10, 0, 0, 0, 0, length}
 
 	binary.LittleEndian.PutUint32(sub[1:], addr)
 
-	return jc.sendSubcommand(sub, nil)
+	// the reply starts with the same address and length
+	reply, e := jc.request(sub, sub[1:], subcommandTimeout, subcommandRetries)
+	if e != nil {
+		return nil, e
+	}
+	if int(length)+5 > len(reply) { // 5: addr + len
+		return nil, fmt.Errorf("SPI read 0x%X: reply too short", addr)
+	}
+	return reply[5 : 5+int(length)], nil
 }
 
 func (jc *joycon) Disconnect() {
 	jc.mu.Lock()
 	defer jc.mu.Unlock()
 
-	if jc.hidDev != nil {
-		jc.hidDev.Close()
-		jc.hidDev = nil
+	if jc.transport != nil {
+		jc.transport.Close()
+		jc.transport = nil
 	}
 }
 func (jc *joycon) Test() {
@@ -184,9 +374,9 @@ func (jc *joycon) sendSubcommand(
 	jc.mu.Lock()
 	defer jc.mu.Unlock()
 
-	hidHandle := jc.hidDev
+	transport := jc.transport
 
-	if hidHandle == nil {
+	if transport == nil {
 		return errors.New("hid handle closed")
 	}
 	jc.packetId++
@@ -202,7 +392,7 @@ func (jc *joycon) sendSubcommand(
 	}
 	packet = append(packet, sub...)
 
-	_, e := hidHandle.Write(packet)
+	_, e := transport.Write(packet)
 	return e
 }
 
@@ -214,79 +404,176 @@ func (jc *joycon) Rumble(freq *RumbleFrequency) error {
 	return jc.sendSubcommand(sub, freq)
 
 }
+
+// Play the vibration pattern in background, it stops the previous one
+func (jc *joycon) PlayRumble(pattern RumblePattern) {
+	jc.rumbler.play(jc.Rumble, pattern)
+}
 func (jc *joycon) decodeBattery(packet []byte) {
+	defer jc.flush()
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
 	prevBattery := jc.battery
 	jc.battery = packet[2] & 0xF0 // battery is high nibble of this byte
 	if prevBattery != jc.battery {
 		lvl, charging := jc.Battery()
-		jc.listener.OnBattery(jc, lvl, charging)
+		jc.queue(Event{Type: Event_Battery, Battery: lvl, Charging: charging})
 	}
 }
 
 func (jc *joycon) decodeButton(packet []byte) {
+	defer jc.flush()
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	jc.updateButtons(ButtonsFromSlice(packet[3:6]))
+}
+
+func (jc *joycon) updateButtons(curr ButtonState) {
 	jc.prevButtons = jc.currButtons
-	jc.currButtons = ButtonsFromSlice(packet[3:6])
+	jc.currButtons = curr
 
 	down := jc.prevButtons.DownMask(jc.currButtons) // all key down
 	up := jc.prevButtons.UpMask(jc.currButtons)     // all key up
 
 	// only trigger event if there is change
 	if !down.IsZero() || !up.IsZero() {
-		jc.listener.OnButton(jc, &down, &up, &jc.currButtons)
+		jc.queue(Event{Type: Event_Button, Down: down, Up: up, Buttons: jc.currButtons})
 	}
 }
 
 func (jc *joycon) decodeStick(packet []byte) {
-	if jc.isCalibrated() { // stick
-		jc.prevStick = jc.currStick
-		p := &Point{}
-
-		if jc.side.IsLeft() {
-			p.X, p.Y = decodeUint12(packet[6:9])
+	defer jc.flush()
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
 
-			jc.currStick[0] = jc.stickCalib[0].Adjust(p)
+	jc.rawStick[0].X, jc.rawStick[0].Y = decodeUint12(packet[6:9])
+	jc.rawStick[1].X, jc.rawStick[1].Y = decodeUint12(packet[9:12])
 
-			// don't fire event if it stays at neutral position
-			if !jc.currStick[0].AtNeutral() || !jc.prevStick[0].AtNeutral() {
-				jc.listener.OnStick(jc, SideLeft, &jc.currStick[0], &jc.prevStick[0])
-			}
+	if jc.isCalibrated() { // stick
+		var curr [2]Ratio
+		if jc.side.IsLeft() {
+			curr[0] = jc.adjustStick(0)
 		}
 		if jc.side.IsRight() {
-			p.X, p.Y = decodeUint12(packet[9:12])
-			jc.currStick[1] = jc.stickCalib[1].Adjust(p)
+			curr[1] = jc.adjustStick(1)
+		}
+		jc.updateSticks(curr)
+	}
+}
 
-			if !jc.currStick[1].AtNeutral() || !jc.prevStick[1].AtNeutral() {
-				jc.listener.OnStick(jc, SideRight, &jc.currStick[1], &jc.prevStick[1])
-			}
+func (jc *joycon) updateSticks(curr [2]Ratio) {
+	jc.prevStick = jc.currStick
+	jc.currStick = curr
+
+	// don't fire event if it stays at neutral position
+	if jc.side.IsLeft() {
+		if !jc.currStick[0].AtNeutral() || !jc.prevStick[0].AtNeutral() {
+			jc.queue(Event{Type: Event_Stick, Side: SideLeft, Stick: jc.currStick[0], PrevStick: jc.prevStick[0]})
+		}
+	}
+	if jc.side.IsRight() {
+		if !jc.currStick[1].AtNeutral() || !jc.prevStick[1].AtNeutral() {
+			jc.queue(Event{Type: Event_Stick, Side: SideRight, Stick: jc.currStick[1], PrevStick: jc.prevStick[1]})
 		}
 	}
 }
 
+// each step locks by itself, and sends its events after unlocking
 func (jc *joycon) decodePacket(packet []byte) {
-	jc.mu.Lock()
-	defer jc.mu.Unlock()
-
 	jc.decodeBattery(packet)
 	jc.decodeButton(packet)
 	jc.decodeStick(packet)
 }
 
+func (jc *joycon) adjustStick(i int) Ratio {
+	ratio := jc.calibOf(i).Adjust(&jc.rawStick[i])
+	busy := !jc.currButtons.IsZero()
+	if jc.drift[i].Correct(&ratio, busy, time.Now()) {
+		side := JoyConSide(SideLeft)
+		if i == 1 {
+			side = SideRight
+		}
+		jc.queue(Event{Type: Event_StickDrift, Side: side, Stick: jc.drift[i].Offset()})
+	}
+	if jc.hostRange[i] != nil {
+		jc.hostRange[i].Apply(&ratio)
+	}
+	if resp := StickResponses[i]; resp != nil {
+		resp.Apply(&ratio)
+	}
+	return ratio
+}
+
+// the host calibration if exists, otherwise the one from SPI
+func (jc *joycon) calibOf(i int) *CalibrationData {
+	if jc.hostCalib[i] != nil {
+		return jc.hostCalib[i]
+	}
+	return &jc.stickCalib[i]
+}
+
 // the stick data is only useful when it's calibrated.
+// The Pro Controller needs both sticks calibrated.
 func (jc *joycon) isCalibrated() bool {
-	if jc.side.IsLeft() {
-		return jc.stickCalib[0] != EmptyCalibrationData
-	} else if jc.side.IsRight() {
-		return jc.stickCalib[1] != EmptyCalibrationData
+	switch jc.side {
+	case SideLeft:
+		return *jc.calibOf(0) != EmptyCalibrationData
+	case SideRight:
+		return *jc.calibOf(1) != EmptyCalibrationData
+	case SideBoth:
+		return *jc.calibOf(0) != EmptyCalibrationData &&
+			*jc.calibOf(1) != EmptyCalibrationData
 	}
 	return false
 }
 
+// Latest raw stick positions, [left, right], for measuring `StickRange`
+func (jc *joycon) RawStick() [2]Point {
+	jc.mu.RLock()
+	defer jc.mu.RUnlock()
+
+	return jc.rawStick
+}
+
+// Use the measured ranges instead of the SPI calibration, nil to keep using SPI
+func (jc *joycon) SetStickRanges(ranges [2]*StickRange) {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	for i, r := range ranges {
+		jc.hostRange[i], jc.hostCalib[i] = r, nil
+		jc.drift[i] = DriftEstimator{} // it was relative to the previous center
+		if r != nil {
+			c := r.Calibration()
+			jc.hostCalib[i] = &c
+		}
+	}
+}
+
+// Current drift offsets being removed, [left, right]
+func (jc *joycon) StickDrift() [2]Ratio {
+	jc.mu.RLock()
+	defer jc.mu.RUnlock()
+
+	return [2]Ratio{jc.drift[0].Offset(), jc.drift[1].Offset()}
+}
+
+func (jc *joycon) isImuCalibrated() bool {
+	jc.mu.RLock()
+	defer jc.mu.RUnlock()
+
+	return jc.imuCalib != EmptyImuCalibration
+}
+
 func gyroPrint(f *GyroFrame) {
 	fmt.Printf("  %7d %7d %7d %7d %7d %7d\n",
 		f.X, f.Y, f.Z, f.Roll, f.Pitch, f.Yaw)
 }
 
 func (jc *joycon) decodeGyroData(packet []byte) {
+	defer jc.flush()
 	jc.mu.Lock()
 	defer jc.mu.Unlock()
 
@@ -325,46 +612,84 @@ func (jc *joycon) decodeGyroData(packet []byte) {
 		return
 	}
 
+	// The timer increases by 1 every 5ms, normally by 3 between reports,
+	// it's used for timing the samples.
+	ticks := byte(3)
+	if jc.gyroTimerValid {
+		ticks = packet[1] - jc.gyroTimer // it wraps around
+	}
+	jc.gyroTimer = packet[1]
+	jc.gyroTimerValid = true
+	if ticks == 0 { // same timer, the report is repeated
+		return
+	}
+	reportSpan := time.Duration(ticks) * SampleInterval
+	jc.gyroClock += reportSpan
+
 	// save the start value
 	if jc.gyroBegin == GyroFrame_Nil {
 		jc.gyroBegin = jc.gyro[0]
 	}
 
-	// use the first frame, adjust it with `gyroBegin` to calculate the offset
-	// and fire event
-	adj := jc.gyro[0]
-	adj.Gyro3D = adj.Adjust(&jc.gyroBegin.Gyro3D)
+	// fire event for each sample, the 1st one is the oldest,
+	// adjust it with `gyroBegin` to calculate the offset
+	for i := 0; i < 3; i++ {
+		adj := jc.gyro[i]
+		jc.imuCalib.Apply(&adj) // physical units from the raw values
+		adj.Gyro3D = adj.Adjust(&jc.gyroBegin.Gyro3D)
+
+		adj.Timestamp = jc.gyroClock - time.Duration(2-i)*SampleInterval
+		adj.Interval = SampleInterval
+		if i == 0 { // longer if there are lost reports in between
+			adj.Interval = reportSpan - 2*SampleInterval
+			if adj.Interval > maxSampleInterval {
+				adj.Interval = maxSampleInterval
+			}
+		}
+
+		jc.bias.Compensate(&adj.Rotation, &adj.Accel, adj.Interval)
+		jc.fusion.Update(&adj.Rotation, &adj.Accel, adj.Interval)
+		adj.Orientation = jc.fusion.Orientation()
 
-	jc.listener.OnGyro(jc, &adj)
+		jc.queue(Event{Type: Event_Gyro, Side: jc.side, Gyro: adj})
+	}
 }
 
+// The 0x21 reply:
+//   - byte 13: ACK, 0x80 bit is set if accepted, lower bits are the type of data
+//   - byte 14: ID of the subcommand being replied
+//   - byte 15~: reply data
 func (jc *joycon) handleSubcommandReply(packet []byte) {
-	packetID := packet[0]
-
-	replyPacketID := byte(0)
-	if packetID == 0x21 {
-		replyPacketID = packet[13] - 0x80
-	} else /* 0x31-0x33 */ {
-		replyPacketID = packet[13]
+	if len(packet) < 15 {
+		return
 	}
+	ack, subId, data := packet[13], packet[14], packet[15:]
 
-	switch replyPacketID {
-	case 0: // If it is a simple ACK, the byte13 is x80 and thus the type of data is x00
-	case 2: // only once, after the BT connection is made.
+	switch subId {
 	case 0x10: // SPI Flash Read
-		jc.handleSPIRead(packet[13:])
-	default:
-		log.Warningf("got subcommand reply packet: %d\n%s", replyPacketID, hex.Dump(packet[:]))
+		if ack&0x80 != 0 {
+			jc.handleSPIRead(packet[13:])
+		}
+	}
+
+	if !jc.deliverReply(subId, ack, data) && ack != 0 {
+		// late reply of a timed out request
+		log.Debugf("unexpected subcommand reply: %02X\n%s", subId, hex.Dump(packet[:]))
 	}
 }
 
 func (jc *joycon) readLoop() {
 	var buffer [0x200]byte // windows max packet size: 0x16a
 
+	// `Disconnect()` resets `jc.transport` while reading
+	transport := jc.transport
+
 	for {
-		n, e := jc.hidDev.Read(buffer[:]) // blocking
+		n, e := transport.Read(buffer[:]) // blocking
+		recv := time.Now()
+		jc.lastReport.Store(recv.UnixNano())
 		if e != nil {
-			jc.listener.OnReadWriteError(jc, e)
+			jc.emit(Event{Type: Event_ReadWriteError, Err: e})
 			return
 		}
 		if n >= len(buffer) {
@@ -375,6 +700,16 @@ func (jc *joycon) readLoop() {
 		if len(packet) == 0 {
 			continue
 		}
+		// e.g. a truncated record of a capture file
+		if min, ok := minReportLen[packet[0]]; ok && len(packet) < min {
+			log.Warningf("%s: report %02X too short: %d bytes", jc.Mac(), packet[0], len(packet))
+			continue
+		}
+		jc.readyOnce.Do(func() {
+			if jc.ready != nil {
+				close(jc.ready)
+			}
+		})
 
 		// fmt.Printf("packet0: %x, n: %x\n", packet[0], n)
 		// if len(packet) > 0x100 {
@@ -398,20 +733,56 @@ func (jc *joycon) readLoop() {
 		// size == 0x16a with lots of trailing zeroes, seems bug of hidapi, one issue of it claims this was fixed but actually it's not
 		// the buffer must be at least 0x16a on Windows
 		case SimpleHid: //0x3F
-			// do nothing, should be switched to StandardFullMode when attached
-			// maybe still have a few packets, just ignore
+			// should be switched to StandardFullMode when attached,
+			// decode it anyway so buttons work before that or if the switching fails
+			jc.decodeSimpleHid(packet)
+
+		case usbInputReport: // 0x81
+			// replies of USB handshake, only for USB connection, nothing to do
 
 		default:
 			log.Warningf("Packet %02X:\n%s", packet[0], hex.Dump(packet))
 		}
+
+		// only these have the timer
+		if packet[0] == 0x21 || packet[0] == StandardFull {
+			jc.stats.record(packet[1], recv, time.Since(recv))
+		}
 	}
 }
 
+// the shortest valid report of each type, shorter ones are dropped
+var minReportLen = map[byte]int{
+	0x21:         15, // with the subcommand ack and id
+	StandardFull: 49, // with 3 IMU samples
+	SimpleHid:    12,
+}
+
+// Statistics of input reports since connected or `ResetStats`
+func (jc *joycon) Stats() ReportStats {
+	return jc.stats.snapshot()
+}
+func (jc *joycon) ResetStats() {
+	jc.stats.reset()
+}
+
+// wait for the first report before setup
+const setupTimeout = 3 * time.Second
+
+// and wait this long after it, the first report comes right after attached
+var setupSettle = 2 * time.Second
+
 const (
 	factoryStickCalibStart = 0x603D
 	factoryStickCalibLen   = 25
 	userStickCalibStart    = 0x8010
 	userStickCalibLen      = 22
+	factoryImuCalibStart   = 0x6020
+	factoryImuCalibLen     = 24
+	userImuCalibStart      = 0x8026 // 2 bytes magic + 24 bytes data
+	userImuCalibLen        = 26
+
+	magicHaveCalibration = 0xA1B2
 )
 
 func (jc *joycon) handleSPIRead(packet []byte) {
@@ -427,32 +798,52 @@ func (jc *joycon) handleSPIRead(packet []byte) {
 		data = packet[7 : 7+length]
 	}
 
-	if addr == factoryStickCalibStart && length == factoryStickCalibLen {
+	// read by `SPIRead()` for information, subcommands are sent one at a time,
+	// so this reply is for it
+	if jc.rawReads.Load() > 0 {
+		return
+	}
+
+	// others are just returned to the caller of `spiRead()`
+	switch {
+	case addr == factoryStickCalibStart && length == factoryStickCalibLen:
 		jc.mu.Lock()
-		jc.stickCalib[0].Parse(data[0:9], SideLeft)
-		jc.stickCalib[1].Parse(data[9:18], SideRight)
+		jc.stickCalib = ParseFactoryStick(data)
+		calib := jc.stickCalib
 		jc.mu.Unlock()
 
-		jc.listener.OnStickCalib(jc, &jc.stickCalib)
-	} else if addr == userStickCalibStart && length == userStickCalibLen {
-		had := false
-		const magicHaveCalibration = 0xA1B2
+		jc.emit(Event{Type: Event_StickCalib, Calib: calib})
+	case addr == userStickCalibStart && length == userStickCalibLen:
+		user := ParseUserStick(data)
 
 		jc.mu.Lock()
-		if binary.LittleEndian.Uint16(data[0:2]) == magicHaveCalibration {
-			jc.stickCalib[0].Parse(data[2:2+9], SideLeft)
-			had = true
+		for i := range user {
+			if user[i] != nil {
+				jc.stickCalib[i] = *user[i]
+			}
 		}
-		if binary.LittleEndian.Uint16(data[11:13]) == magicHaveCalibration {
-			jc.stickCalib[1].Parse(data[13:13+9], SideRight)
-			had = true
+		calib := jc.stickCalib
+		jc.mu.Unlock()
+
+		if user[0] != nil || user[1] != nil {
+			jc.emit(Event{Type: Event_StickCalib, Calib: calib})
+		}
+	case addr == factoryImuCalibStart && length == factoryImuCalibLen:
+		jc.mu.Lock()
+		if !jc.imuUserCalib {
+			jc.imuCalib.Parse(data)
 		}
 		jc.mu.Unlock()
 
-		if had {
-			jc.listener.OnStickCalib(jc, &jc.stickCalib)
+		log.Debugf("%s: IMU factory calibration: %v", jc.Mac(), jc.imuCalib)
+	case addr == userImuCalibStart && length == userImuCalibLen:
+		if user := ParseUserImu(data); user != nil {
+			jc.mu.Lock()
+			jc.imuCalib = *user
+			jc.imuUserCalib = true
+			jc.mu.Unlock()
+
+			log.Debugf("%s: IMU user calibration: %v", jc.Mac(), jc.imuCalib)
 		}
-	} else {
-		log.Infof("%s: SPI read returned [%x+%d]\n%s", jc.Mac(), addr, length, hex.Dump(data))
 	}
 }
@@ -0,0 +1,304 @@
+package joycon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// records all events for checking
+type probe struct {
+	nopListener
+
+	buttons []ButtonState // `down` of each button event
+	sticks  []Ratio
+	gyros   []GyroFrame
+	calib   int
+	drifts  int
+	err     chan error
+}
+
+func (p *probe) OnButton(jc Controller, down, up, curr *ButtonState) {
+	p.buttons = append(p.buttons, *down)
+}
+func (p *probe) OnStick(jc Controller, t JoyConSide, curr, prev *Ratio) {
+	p.sticks = append(p.sticks, *curr)
+}
+func (p *probe) OnGyro(jc Controller, t JoyConSide, f *GyroFrame) {
+	p.gyros = append(p.gyros, *f)
+}
+func (p *probe) OnStickCalib(Controller, *[2]CalibrationData) {
+	p.calib++
+}
+func (p *probe) OnStickDrift(Controller, JoyConSide, *Ratio) {
+	p.drifts++
+}
+func (p *probe) OnReadWriteError(jc Controller, e error) {
+	if p.err != nil {
+		p.err <- e
+	}
+}
+
+func encodeUint12(d1, d2 uint16) []byte {
+	return []byte{byte(d1), byte(d1>>8)&0xF | byte(d2<<4), byte(d2 >> 4)}
+}
+
+// a 0x30 report with specified buttons and right stick position
+func newReport(buttons ButtonState, x, y uint16) []byte {
+	packet := make([]byte, 49)
+	packet[0] = StandardFull
+	copy(packet[3:6], buttons[:])
+	copy(packet[9:12], encodeUint12(x, y))
+	return packet
+}
+
+// a 0x30 report with both sticks, left stick at [lx, ly], right at [rx, ry]
+func newDualReport(lx, ly, rx, ry uint16) []byte {
+	packet := newReport(ButtonState{}, rx, ry)
+	copy(packet[6:9], encodeUint12(lx, ly))
+	return packet
+}
+
+// a 0x21 reply of SPI read on factory stick calibration,
+// center: 0x800, range: +-0x500 for both sticks
+func newCalibReply() []byte {
+	packet := make([]byte, 49)
+	packet[0] = 0x21
+	packet[13] = 0x90
+	packet[14] = 0x10
+	binary.LittleEndian.PutUint32(packet[15:], factoryStickCalibStart)
+	packet[19] = factoryStickCalibLen
+
+	data := packet[20:]
+	copy(data[0:], encodeUint12(0x500, 0x500)) // left: max, center, min
+	copy(data[3:], encodeUint12(0x800, 0x800))
+	copy(data[6:], encodeUint12(0x500, 0x500))
+	copy(data[9:], encodeUint12(0x800, 0x800)) // right: center, min, max
+	copy(data[12:], encodeUint12(0x500, 0x500))
+	copy(data[15:], encodeUint12(0x500, 0x500))
+	return packet
+}
+
+func newTestJoycon(side JoyConSide, p *probe) *joycon {
+	jc := &joycon{side: side, fusion: NewMadgwick()}
+	if p != nil {
+		jc.Subscribe(p)
+	}
+	return jc
+}
+
+func TestDecodeButton(t *testing.T) {
+	p := &probe{}
+	jc := newTestJoycon(SideRight, p)
+
+	jc.decodeButton(newReport(ButtonState{byte(Button_R_A)}, 0, 0))
+	jc.decodeButton(newReport(ButtonState{byte(Button_R_A)}, 0, 0)) // no change, no event
+	jc.decodeButton(newReport(ButtonState{}, 0, 0))
+
+	assert.Equal(t, 2, len(p.buttons))
+	assert.True(t, p.buttons[0].Has(Button_R_A))
+	assert.True(t, p.buttons[1].IsZero())
+}
+
+func TestHandleSPIRead(t *testing.T) {
+	p := &probe{}
+	jc := newTestJoycon(SideRight, p)
+
+	jc.handleSubcommandReply(newCalibReply())
+
+	assert.Equal(t, 1, p.calib)
+	assert.True(t, jc.isCalibrated())
+	assert.Equal(t, uint16(0x800), jc.stickCalib[1].xCenter)
+	assert.Equal(t, uint16(0x500), jc.stickCalib[1].yMaxOff)
+}
+
+func TestDecodeStick(t *testing.T) {
+	p := &probe{}
+	jc := newTestJoycon(SideRight, p)
+
+	// not calibrated, ignored
+	jc.decodeStick(newReport(ButtonState{}, 0xD00, 0x800))
+	assert.Equal(t, 0, len(p.sticks))
+
+	jc.handleSubcommandReply(newCalibReply())
+
+	jc.decodeStick(newReport(ButtonState{}, 0x800, 0x800)) // neutral, ignored
+	jc.decodeStick(newReport(ButtonState{}, 0xD00, 0x800)) // most right
+	jc.decodeStick(newReport(ButtonState{}, 0x800, 0x300)) // most down
+
+	assert.Equal(t, []Ratio{{X: 1, Y: 0}, {X: 0, Y: -1}}, p.sticks)
+}
+
+func TestDecodeStickPro(t *testing.T) {
+	p := &probe{}
+	jc := newTestJoycon(SideBoth, p)
+
+	// only the right stick calibrated, not enough for the Pro Controller
+	jc.stickCalib[1] = CalibrationData{0x500, 0x800, 0x500, 0x500, 0x800, 0x500}
+	assert.False(t, jc.isCalibrated())
+
+	jc.handleSubcommandReply(newCalibReply())
+	assert.True(t, jc.isCalibrated())
+
+	jc.decodeStick(newDualReport(0x300, 0x800, 0x800, 0xD00)) // left: most left, right: most up
+
+	assert.Equal(t, []Ratio{{X: -1, Y: 0}, {X: 0, Y: 1}}, p.sticks)
+}
+
+func TestDecodeGyroData(t *testing.T) {
+	p := &probe{}
+	jc := newTestJoycon(SideRight, p)
+
+	packet := make([]byte, 49)
+	sample := func(timer byte, yaw int16) {
+		packet[1] = timer
+		for i := 0; i < 3; i++ {
+			binary.LittleEndian.PutUint16(packet[13+2*(i*6+0):], 100)
+			binary.LittleEndian.PutUint16(packet[13+2*(i*6+5):], uint16(yaw+int16(i)))
+		}
+		jc.decodeGyroData(packet)
+	}
+
+	sample(0, 10) // gyro off, ignored
+	assert.Equal(t, 0, len(p.gyros))
+
+	jc.gyroOn = true
+	sample(254, 10)
+	sample(254, 10) // same timer, ignored
+	sample(1, 30)   // timer wraps, 3 ticks
+	sample(10, 50)  // 2 reports lost
+
+	assert.Equal(t, 9, len(p.gyros))        // 3 samples each
+	assert.Equal(t, int16(0), p.gyros[3].X) // offset to the first frame
+	assert.Equal(t, []int16{30, 31, 32}, []int16{p.gyros[3].Yaw, p.gyros[4].Yaw, p.gyros[5].Yaw})
+
+	assert.Equal(t, 5*time.Millisecond, p.gyros[0].Timestamp)
+	assert.Equal(t, 15*time.Millisecond, p.gyros[2].Timestamp)
+	assert.Equal(t, 20*time.Millisecond, p.gyros[3].Timestamp)
+	assert.Equal(t, 5*time.Millisecond, p.gyros[3].Interval)
+	assert.Equal(t, 75*time.Millisecond, p.gyros[8].Timestamp)
+	assert.Equal(t, 35*time.Millisecond, p.gyros[6].Interval)
+}
+
+// record reports from one transport, then replay them through `readLoop`
+func TestCaptureReplay(t *testing.T) {
+	src := &sliceTransport{reports: [][]byte{
+		newCalibReply(),
+		newReport(ButtonState{byte(Button_R_B)}, 0xD00, 0x800),
+	}}
+
+	buf := &closeBuffer{}
+	rec, e := NewRecorder(src, buf, SideRight, "70:48:f7:76:bc:87")
+	assert.Nil(t, e)
+
+	var report [0x200]byte
+	for {
+		if _, e := rec.Read(report[:]); e != nil {
+			break
+		}
+	}
+	rec.Write([]byte{0x01, 0x00})
+
+	rp, e := NewReplay(bytes.NewReader(buf.Bytes()), false)
+	assert.Nil(t, e)
+	assert.Equal(t, JoyConSide(SideRight), rp.Side())
+	assert.Equal(t, "70:48:f7:76:bc:87", rp.Mac())
+
+	p := &probe{err: make(chan error, 1)}
+	jc := newTestJoycon(rp.Side(), p)
+	jc.transport = rp
+	go jc.readLoop()
+
+	select {
+	case e := <-p.err:
+		assert.Equal(t, io.EOF, e)
+	case <-time.After(time.Second):
+		t.Fatal("replay not finished")
+	}
+	assert.Equal(t, 1, p.calib)
+	assert.Equal(t, 1, len(p.buttons))
+	assert.Equal(t, []Ratio{{X: 1, Y: 0}}, p.sticks)
+}
+
+type sliceTransport struct {
+	reports [][]byte
+}
+
+func (s *sliceTransport) Read(report []byte) (int, error) {
+	if len(s.reports) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(report, s.reports[0])
+	s.reports = s.reports[1:]
+	return n, nil
+}
+func (s *sliceTransport) Write(report []byte) (int, error) { return len(report), nil }
+func (s *sliceTransport) Close() error                     { return nil }
+
+type closeBuffer struct {
+	bytes.Buffer
+}
+
+func (b *closeBuffer) Close() error { return nil }
+
+func TestImuCalibration(t *testing.T) {
+	p := &probe{}
+	jc := newTestJoycon(SideRight, p)
+
+	reply := func(addr uint32, length byte, data []byte) []byte {
+		packet := make([]byte, 49)
+		packet[0] = 0x21
+		packet[13] = 0x90
+		packet[14] = 0x10
+		binary.LittleEndian.PutUint32(packet[15:], addr)
+		packet[19] = length
+		copy(packet[20:], data)
+		return packet
+	}
+	calib := func(accCoeff, gyroOrigin, gyroCoeff int16) []byte {
+		b := make([]byte, 24)
+		for i := 0; i < 3; i++ {
+			binary.LittleEndian.PutUint16(b[6+2*i:], uint16(accCoeff))
+			binary.LittleEndian.PutUint16(b[12+2*i:], uint16(gyroOrigin))
+			binary.LittleEndian.PutUint16(b[18+2*i:], uint16(gyroCoeff))
+		}
+		return b
+	}
+
+	// user calibration without magic, ignored
+	jc.handleSubcommandReply(reply(userImuCalibStart, userImuCalibLen, make([]byte, 26)))
+	assert.False(t, jc.isImuCalibrated())
+
+	jc.handleSubcommandReply(reply(factoryImuCalibStart, factoryImuCalibLen, calib(16384, 10, 13381)))
+	assert.True(t, jc.isImuCalibrated())
+
+	// user calibration overrides the factory one, and stays
+	user := append([]byte{0xB2, 0xA1}, calib(8192, 0, 936)...)
+	jc.handleSubcommandReply(reply(userImuCalibStart, userImuCalibLen, user))
+	jc.handleSubcommandReply(reply(factoryImuCalibStart, factoryImuCalibLen, calib(16384, 10, 13381)))
+
+	f := GyroFrame{Gyro3D: Gyro3D{X: 2048}, Acceleration: Acceleration{Yaw: 100}}
+	jc.imuCalib.Apply(&f)
+	assert.InDelta(t, 1.0, f.Accel.X, 1e-9)        // 2048 * 4 / 8192
+	assert.InDelta(t, 100.0, f.Rotation.Yaw, 1e-9) // 100 * 936 / 936
+}
+
+func TestTruncatedReport(t *testing.T) {
+	p := &probe{err: make(chan error, 1)}
+	jc := newTestJoycon(SideRight, p)
+	tr := newReplyTransport(func([]byte) []byte { return nil })
+	jc.transport = tr
+
+	tr.reports <- newReport(ButtonState{}, 0, 0)[:20]
+	tr.reports <- newSubcommandReply(0x90, 0x10, nil)[:10]
+	tr.reports <- newReport(ButtonState{byte(Button_R_A)}, 0, 0)
+	close(tr.reports)
+
+	jc.readLoop() // returns at EOF, without panic
+	assert.Equal(t, io.EOF, <-p.err)
+	assert.Equal(t, 1, len(p.buttons))
+}
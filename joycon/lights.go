@@ -0,0 +1,110 @@
+package joycon
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// The player lights only last for 1 frame on Linux,
+// so the desired pattern is re-sent periodically
+var LightRefresh = 500 * time.Millisecond
+
+var refreshLights = runtime.GOOS == "linux"
+
+// Only the steady ones, re-sending a flashing one may restart the flashing
+func needsRefresh(pattern byte) bool {
+	return refreshLights && pattern&0xF0 == 0
+}
+
+// Player lights from a string, one char for each of the 4 lights,
+// '1': on, 'f': flashing, '0': off.
+// e.g. "1f00": the first on, the second flashing
+func ParseLights(s string) (byte, error) {
+	if len(s) != 4 {
+		return 0, fmt.Errorf("lights should be 4 chars of 1/f/0, e.g. '1f00', got: '%s'", s)
+	}
+	var pattern byte
+	for i, c := range strings.ToLower(s) {
+		switch c {
+		case '1':
+			pattern |= 1 << i
+		case 'f':
+			pattern |= 1 << (i + 4)
+		case '0':
+		default:
+			return 0, fmt.Errorf("invalid light '%c' in '%s', should be 1/f/0", c, s)
+		}
+	}
+	return pattern, nil
+}
+
+// One step of the HOME LED pattern, all values are 0~15
+type HomeLightCycle struct {
+	Intensity byte // brightness of this step
+	Fade      byte // fading time to this step, multiplier of `HomeLight.Base`
+	Duration  byte // how long it stays, multiplier of `HomeLight.Base`
+}
+
+/*
+* The HOME button ring LED, subcommand 0x38
+* see: https://github.com/dekuNukem/Nintendo_Switch_Reverse_Engineering/blob/master/bluetooth_hid_subcommands_notes.md#subcommand-0x38-set-home-light
+ */
+type HomeLight struct {
+	Base      byte // base duration of a step, 1~15 => 8~175ms, 0: off
+	Intensity byte // starting brightness, 0~15
+	Repeat    byte // times of the whole cycle, 0: forever
+	Cycles    []HomeLightCycle
+}
+
+// max steps of a pattern
+const maxHomeLightCycles = 15
+
+var HomeLightOff = &HomeLight{}
+
+var HomeLightPatterns = map[string]*HomeLight{
+	"off": HomeLightOff,
+	"on": {Base: 1, Intensity: 0xF, Cycles: []HomeLightCycle{
+		{Intensity: 0xF, Duration: 0xF},
+	}},
+	"dim": {Base: 1, Intensity: 0x4, Cycles: []HomeLightCycle{
+		{Intensity: 0x4, Duration: 0xF},
+	}},
+	"pulse": {Base: 0x8, Cycles: []HomeLightCycle{
+		{Intensity: 0xF, Fade: 0xF, Duration: 0x2},
+		{Intensity: 0x0, Fade: 0xF, Duration: 0x2},
+	}},
+	"blink": {Base: 0xF, Cycles: []HomeLightCycle{
+		{Intensity: 0xF, Duration: 0x2},
+		{Intensity: 0x0, Duration: 0x2},
+	}},
+}
+
+// The subcommand data following 0x38
+func (h *HomeLight) Encode() ([]byte, error) {
+	n := len(h.Cycles)
+	if n > maxHomeLightCycles {
+		return nil, fmt.Errorf("HOME light supports at most %d cycles, got: %d", maxHomeLightCycles, n)
+	}
+	data := make([]byte, 25)
+	data[0] = byte(n)<<4 | h.Base&0xF
+	data[1] = h.Intensity<<4 | h.Repeat&0xF
+
+	// every 2 cycles take 3 bytes:
+	//   intensity 1 | intensity 2, fade 1 | duration 1, fade 2 | duration 2
+	for i := 0; i < n; i += 2 {
+		c1 := h.Cycles[i]
+		c2 := HomeLightCycle{}
+		if i+1 < n {
+			c2 = h.Cycles[i+1]
+		}
+		off := 2 + i/2*3
+		data[off] = c1.Intensity<<4 | c2.Intensity&0xF
+		data[off+1] = c1.Fade<<4 | c1.Duration&0xF
+		if i+1 < n { // the 15th has no pair
+			data[off+2] = c2.Fade<<4 | c2.Duration&0xF
+		}
+	}
+	return data, nil
+}
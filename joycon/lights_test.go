@@ -0,0 +1,87 @@
+package joycon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLights(t *testing.T) {
+	p, e := ParseLights("1f00")
+	assert.Nil(t, e)
+	assert.Equal(t, byte(0x21), p)
+
+	p, e = ParseLights("0001")
+	assert.Nil(t, e)
+	assert.Equal(t, byte(0x08), p)
+
+	_, e = ParseLights("1f0")
+	assert.NotNil(t, e)
+	_, e = ParseLights("1x00")
+	assert.NotNil(t, e)
+}
+
+func TestHomeLightEncode(t *testing.T) {
+	data, e := HomeLightPatterns["pulse"].Encode()
+	assert.Nil(t, e)
+	assert.Equal(t, 25, len(data))
+	assert.Equal(t, []byte{0x28, 0x00, 0xF0, 0xF2, 0xF2, 0x00}, data[:6])
+
+	// 3 cycles, the last one has no pair
+	h := &HomeLight{Base: 1, Intensity: 2, Repeat: 3, Cycles: []HomeLightCycle{
+		{1, 2, 3}, {4, 5, 6}, {7, 8, 9},
+	}}
+	data, _ = h.Encode()
+	assert.Equal(t, []byte{0x31, 0x23, 0x14, 0x23, 0x56, 0x70, 0x89, 0x00}, data[:8])
+
+	// 15 cycles fit in 25 bytes
+	_, e = (&HomeLight{Cycles: make([]HomeLightCycle, 15)}).Encode()
+	assert.Nil(t, e)
+	_, e = (&HomeLight{Cycles: make([]HomeLightCycle, 16)}).Encode()
+	assert.NotNil(t, e)
+}
+
+func TestKeepLights(t *testing.T) {
+	refresh, enabled := LightRefresh, refreshLights
+	LightRefresh, refreshLights = 10*time.Millisecond, true
+	defer func() { LightRefresh, refreshLights = refresh, enabled }()
+
+	assert.True(t, needsRefresh(0x01))
+	assert.False(t, needsRefresh(0x10)) // flashing
+
+	tr := newReplyTransport(func(sub []byte) []byte {
+		if sub[0] == 0x30 {
+			return newSubcommandReply(0x80, 0x30, nil)
+		}
+		return nil
+	})
+	jc := newTestJoycon(SideRight, &probe{})
+	jc.transport = tr
+	go jc.readLoop()
+	stopped := make(chan struct{})
+	go func() {
+		jc.keepLights()
+		close(stopped)
+	}()
+
+	assert.Nil(t, jc.SetLights(0x01))
+
+	// re-sent periodically
+	assert.Eventually(t, func() bool {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		return tr.writes > 3
+	}, 2*time.Second, 5*time.Millisecond)
+
+	// stops after disconnected
+	jc.Disconnect()
+	assert.Eventually(t, func() bool {
+		select {
+		case <-stopped:
+			return true
+		default:
+			return false
+		}
+	}, 2*time.Second, 5*time.Millisecond)
+}
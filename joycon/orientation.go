@@ -0,0 +1,137 @@
+package joycon
+
+import (
+	"math"
+	"time"
+)
+
+type Quaternion struct {
+	W, X, Y, Z float64
+}
+
+var IdentityQuaternion = Quaternion{W: 1}
+
+func (q Quaternion) normalize() Quaternion {
+	n := math.Sqrt(q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+	if n == 0 {
+		return IdentityQuaternion
+	}
+	return Quaternion{q.W / n, q.X / n, q.Y / n, q.Z / n}
+}
+
+// Absolute orientation of the controller,
+// yaw is relative to the direction when gyro is enabled, there is no magnetometer.
+type Orientation struct {
+	Quaternion
+
+	// in degrees, Z-Y-X order, same axes as `AngularVelocity`
+	Roll, Pitch, Yaw float64
+}
+
+func (q Quaternion) Orientation() Orientation {
+	toDeg := 180 / math.Pi
+
+	sinPitch := 2 * (q.W*q.Y - q.Z*q.X)
+	sinPitch = math.Max(-1, math.Min(1, sinPitch))
+
+	return Orientation{
+		Quaternion: q,
+		Roll:       math.Atan2(2*(q.W*q.X+q.Y*q.Z), 1-2*(q.X*q.X+q.Y*q.Y)) * toDeg,
+		Pitch:      math.Asin(sinPitch) * toDeg,
+		Yaw:        math.Atan2(2*(q.W*q.Z+q.X*q.Y), 1-2*(q.Y*q.Y+q.Z*q.Z)) * toDeg,
+	}
+}
+
+// Madgwick filter, fuses gyro and accelerometer into orientation.
+// The gyro is integrated, and the accelerometer(gravity) slowly corrects the roll/pitch drift.
+// ref: https://x-io.co.uk/open-source-imu-and-ahrs-algorithms/
+type Madgwick struct {
+	// how fast the accelerometer corrects the gyro, larger is faster but noisier
+	Beta float64
+
+	q           Quaternion
+	initialized bool
+}
+
+func NewMadgwick() *Madgwick {
+	return &Madgwick{Beta: 0.1, q: IdentityQuaternion}
+}
+
+func (m *Madgwick) Reset() {
+	m.q = IdentityQuaternion
+	m.initialized = false
+}
+
+func (m *Madgwick) Orientation() Orientation {
+	return m.q.Orientation()
+}
+
+// start from the gravity direction, so it doesn't take seconds to converge
+func (m *Madgwick) initFromGravity(acc *Vector3) {
+	roll := math.Atan2(acc.Y, acc.Z)
+	pitch := math.Atan2(-acc.X, math.Sqrt(acc.Y*acc.Y+acc.Z*acc.Z))
+
+	cr, sr := math.Cos(roll/2), math.Sin(roll/2)
+	cp, sp := math.Cos(pitch/2), math.Sin(pitch/2)
+	m.q = Quaternion{
+		W: cr * cp,
+		X: sr * cp,
+		Y: cr * sp,
+		Z: -sr * sp,
+	}.normalize()
+}
+
+// Update with one sample, gyro in deg/s and accelerometer in G
+func (m *Madgwick) Update(gyro *AngularVelocity, acc *Vector3, dt time.Duration) {
+	ax, ay, az := acc.X, acc.Y, acc.Z
+	hasAcc := ax != 0 || ay != 0 || az != 0
+
+	if !m.initialized {
+		if hasAcc {
+			m.initFromGravity(acc)
+		}
+		m.initialized = true
+	}
+
+	toRad := math.Pi / 180
+	gx, gy, gz := gyro.Roll*toRad, gyro.Pitch*toRad, gyro.Yaw*toRad
+	q0, q1, q2, q3 := m.q.W, m.q.X, m.q.Y, m.q.Z
+
+	// rate of change from gyro
+	qDot0 := 0.5 * (-q1*gx - q2*gy - q3*gz)
+	qDot1 := 0.5 * (q0*gx + q2*gz - q3*gy)
+	qDot2 := 0.5 * (q0*gy - q1*gz + q3*gx)
+	qDot3 := 0.5 * (q0*gz + q1*gy - q2*gx)
+
+	// feedback from the accelerometer
+	if hasAcc {
+		n := math.Sqrt(ax*ax + ay*ay + az*az)
+		ax, ay, az = ax/n, ay/n, az/n
+
+		_2q0, _2q1, _2q2, _2q3 := 2*q0, 2*q1, 2*q2, 2*q3
+		_4q0, _4q1, _4q2 := 4*q0, 4*q1, 4*q2
+		_8q1, _8q2 := 8*q1, 8*q2
+		q0q0, q1q1, q2q2, q3q3 := q0*q0, q1*q1, q2*q2, q3*q3
+
+		// gradient descent step
+		s0 := _4q0*q2q2 + _2q2*ax + _4q0*q1q1 - _2q1*ay
+		s1 := _4q1*q3q3 - _2q3*ax + 4*q0q0*q1 - _2q0*ay - _4q1 + _8q1*q1q1 + _8q1*q2q2 + _4q1*az
+		s2 := 4*q0q0*q2 + _2q0*ax + _4q2*q3q3 - _2q3*ay - _4q2 + _8q2*q1q1 + _8q2*q2q2 + _4q2*az
+		s3 := 4*q1q1*q3 - _2q1*ax + 4*q2q2*q3 - _2q2*ay
+
+		if sn := math.Sqrt(s0*s0 + s1*s1 + s2*s2 + s3*s3); sn > 0 {
+			qDot0 -= m.Beta * s0 / sn
+			qDot1 -= m.Beta * s1 / sn
+			qDot2 -= m.Beta * s2 / sn
+			qDot3 -= m.Beta * s3 / sn
+		}
+	}
+
+	sec := dt.Seconds()
+	m.q = Quaternion{
+		W: q0 + qDot0*sec,
+		X: q1 + qDot1*sec,
+		Y: q2 + qDot2*sec,
+		Z: q3 + qDot3*sec,
+	}.normalize()
+}
@@ -0,0 +1,31 @@
+package joycon
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMadgwick(t *testing.T) {
+	// lying flat, turn 90 degrees on yaw in 1 second
+	m := NewMadgwick()
+	flat := &Vector3{Z: 1}
+	for i := 0; i < 200; i++ {
+		m.Update(&AngularVelocity{Yaw: 90}, flat, SampleInterval)
+	}
+	o := m.Orientation()
+	assert.InDelta(t, 90, o.Yaw, 1)
+	assert.InDelta(t, 0, o.Roll, 1)
+	assert.InDelta(t, 0, o.Pitch, 1)
+
+	// tilted 30 degrees on roll, still
+	m.Reset()
+	rad := 30 * math.Pi / 180
+	tilted := &Vector3{Y: math.Sin(rad), Z: math.Cos(rad)}
+	for i := 0; i < 10; i++ {
+		m.Update(&AngularVelocity{}, tilted, 5*time.Millisecond)
+	}
+	assert.InDelta(t, 30, m.Orientation().Roll, 1)
+}
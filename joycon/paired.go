@@ -0,0 +1,262 @@
+package joycon
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Paired combines a left and a right Joy-Con into one virtual controller,
+// it works like a Pro Controller:
+//   - buttons of both sides are merged into one ButtonState
+//   - stick/gyro events keep the side they come from
+//   - commands like rumble/lights are sent to both halves
+type Paired struct {
+	mu sync.Mutex
+
+	// held from merging a state to sending it, so the events of both halves
+	// are sent in the order they're merged
+	emitMu sync.Mutex
+
+	halves [2]Controller // [left, right]
+	unbind [2]RemoveListenerFn
+
+	subs broadcaster
+
+	buttons [2]ButtonState // current buttons of each half
+	merged  ButtonState
+
+	stickCalib [2]CalibrationData
+
+	battery  [2]int8
+	charging [2]bool
+}
+
+func NewPaired(left, right Controller) *Paired {
+	p := &Paired{
+		halves: [2]Controller{left, right},
+	}
+	p.battery[0], p.charging[0] = left.Battery()
+	p.battery[1], p.charging[1] = right.Battery()
+
+	// listen to both halves
+	p.unbind[0] = left.Subscribe(p)
+	p.unbind[1] = right.Subscribe(p)
+	return p
+}
+
+func (p *Paired) Left() Controller  { return p.halves[0] }
+func (p *Paired) Right() Controller { return p.halves[1] }
+
+func (p *Paired) Mac() string {
+	return fmt.Sprintf("%s & %s", p.halves[0].Mac(), p.halves[1].Mac())
+}
+
+func (p *Paired) Side() JoyConSide {
+	return SideBoth
+}
+
+func (p *Paired) Disconnect() {
+	p.Split()
+	for _, jc := range p.halves {
+		jc.Disconnect()
+	}
+}
+
+// Stop listening to the halves without disconnecting them,
+// so they can be used alone again
+func (p *Paired) Split() {
+	for i := range p.halves {
+		p.unbind[i]()
+	}
+}
+
+// The R/W error of one half, the other one may still work
+type HalfError struct {
+	Half Controller
+	Err  error
+}
+
+func (e *HalfError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Half.Side().String(), e.Err.Error())
+}
+func (e *HalfError) Unwrap() error { return e.Err }
+
+// run `fn` for both halves, return the first error
+func (p *Paired) both(fn func(Controller) error) error {
+	var ret error
+	for _, jc := range p.halves {
+		if e := fn(jc); e != nil && ret == nil {
+			ret = e
+		}
+	}
+	return ret
+}
+
+func (p *Paired) ShutdownBT() error {
+	return p.both(func(jc Controller) error { return jc.ShutdownBT() })
+}
+
+func (p *Paired) Subscribe(lsn EventListener) RemoveListenerFn {
+	return p.subs.subscribe(lsn)
+}
+func (p *Paired) SubscribeChan(buffer int, drop DropPolicy) (<-chan Event, RemoveListenerFn) {
+	return p.subs.subscribeChan(buffer, drop)
+}
+
+// The lower battery of the two halves,
+// `charging` is the state of that half.
+func (p *Paired) Battery() (int8, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.lowerBattery()
+}
+func (p *Paired) lowerBattery() (int8, bool) {
+	if p.battery[0] <= p.battery[1] {
+		return p.battery[0], p.charging[0]
+	}
+	return p.battery[1], p.charging[1]
+}
+
+func (p *Paired) EnableGyro(isOn bool) error {
+	return p.both(func(jc Controller) error { return jc.EnableGyro(isOn) })
+}
+
+// Each IMU has its own bias, this is the left one,
+// use `Left()`/`Right()` to access them separately.
+func (p *Paired) GyroBias() AngularVelocity {
+	return p.halves[0].GyroBias()
+}
+func (p *Paired) SetGyroBias(bias AngularVelocity) {
+	p.both(func(jc Controller) error { jc.SetGyroBias(bias); return nil })
+}
+func (p *Paired) Rumble(freq *RumbleFrequency) error {
+	return p.both(func(jc Controller) error { return jc.Rumble(freq) })
+}
+func (p *Paired) PlayRumble(pattern RumblePattern) {
+	p.both(func(jc Controller) error { jc.PlayRumble(pattern); return nil })
+}
+func (p *Paired) SetLights(pattern byte) error {
+	return p.both(func(jc Controller) error { return jc.SetLights(pattern) })
+}
+func (p *Paired) SetHomeLight(h *HomeLight) error {
+	return p.both(func(jc Controller) error { return jc.SetHomeLight(h) })
+}
+
+// the left stick from the left half, the right stick from the right half
+func (p *Paired) RawStick() [2]Point {
+	return [2]Point{p.halves[0].RawStick()[0], p.halves[1].RawStick()[1]}
+}
+
+// reports of both halves
+func (p *Paired) Stats() ReportStats {
+	s := p.halves[0].Stats()
+	other := p.halves[1].Stats()
+	s.merge(&other)
+	return s
+}
+func (p *Paired) ResetStats() {
+	p.both(func(jc Controller) error { jc.ResetStats(); return nil })
+}
+func (p *Paired) StickDrift() [2]Ratio {
+	return [2]Ratio{p.halves[0].StickDrift()[0], p.halves[1].StickDrift()[1]}
+}
+func (p *Paired) SetStickRanges(ranges [2]*StickRange) {
+	p.halves[0].SetStickRanges([2]*StickRange{ranges[0], nil})
+	p.halves[1].SetStickRanges([2]*StickRange{nil, ranges[1]})
+}
+func (p *Paired) CalibrateStick() error {
+	return p.both(func(jc Controller) error { return jc.CalibrateStick() })
+}
+func (p *Paired) CalibrateIMU() error {
+	return p.both(func(jc Controller) error { return jc.CalibrateIMU() })
+}
+func (p *Paired) Test() {
+	p.both(func(jc Controller) error { jc.Test(); return nil })
+}
+
+// 0 for left, 1 for right
+func (p *Paired) indexOf(jc Controller) int {
+	if jc == p.halves[0] {
+		return 0
+	}
+	return 1
+}
+
+// ---- events from both halves ----
+// `p.mu` is released before the events are sent,
+// the merged ones hold `p.emitMu` until sent, it's never taken by the halves
+
+func (p *Paired) emit(ev Event) {
+	ev.Source = p
+	p.subs.emit(&ev)
+}
+
+func (p *Paired) OnReadWriteError(jc Controller, e error) {
+	p.emit(Event{Type: Event_ReadWriteError, Err: &HalfError{Half: jc, Err: e}})
+}
+
+func (p *Paired) OnButton(jc Controller, _, _, curr *ButtonState) {
+	p.emitMu.Lock()
+	defer p.emitMu.Unlock()
+
+	p.mu.Lock()
+	p.buttons[p.indexOf(jc)] = *curr
+
+	prev := p.merged
+	for i := range p.merged {
+		p.merged[i] = p.buttons[0][i] | p.buttons[1][i]
+	}
+	merged := p.merged
+	p.mu.Unlock()
+
+	down := prev.DownMask(merged)
+	up := prev.UpMask(merged)
+
+	if !down.IsZero() || !up.IsZero() {
+		p.emit(Event{Type: Event_Button, Down: down, Up: up, Buttons: merged})
+	}
+}
+
+func (p *Paired) OnStick(jc Controller, side JoyConSide, curr, prev *Ratio) {
+	p.emit(Event{Type: Event_Stick, Side: side, Stick: *curr, PrevStick: *prev})
+}
+
+func (p *Paired) OnStickCalib(jc Controller, calib *[2]CalibrationData) {
+	p.emitMu.Lock()
+	defer p.emitMu.Unlock()
+
+	p.mu.Lock()
+	i := p.indexOf(jc)
+	p.stickCalib[i] = calib[i]
+	merged := p.stickCalib
+	p.mu.Unlock()
+
+	p.emit(Event{Type: Event_StickCalib, Calib: merged})
+}
+
+func (p *Paired) OnStickDrift(jc Controller, side JoyConSide, offset *Ratio) {
+	p.emit(Event{Type: Event_StickDrift, Side: side, Stick: *offset})
+}
+
+func (p *Paired) OnGyro(jc Controller, side JoyConSide, frame *GyroFrame) {
+	p.emit(Event{Type: Event_Gyro, Side: side, Gyro: *frame})
+}
+
+func (p *Paired) OnBattery(jc Controller, level int8, charging bool) {
+	p.emitMu.Lock()
+	defer p.emitMu.Unlock()
+
+	p.mu.Lock()
+	prevLevel, prevCharging := p.lowerBattery()
+
+	i := p.indexOf(jc)
+	p.battery[i], p.charging[i] = level, charging
+
+	lvl, chg := p.lowerBattery()
+	p.mu.Unlock()
+
+	if lvl != prevLevel || chg != prevCharging {
+		p.emit(Event{Type: Event_Battery, Battery: lvl, Charging: chg})
+	}
+}
@@ -0,0 +1,56 @@
+package joycon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPairedButtons(t *testing.T) {
+	left := newTestJoycon(SideLeft, nil)
+	right := newTestJoycon(SideRight, nil)
+
+	p := NewPaired(left, right)
+	pb := &probe{}
+	p.Subscribe(pb)
+
+	zl := ButtonState{0, 0, byte(Button_L_ZL & 0xFF)}
+	zr := ButtonState{byte(Button_R_ZR), 0, 0}
+
+	left.decodeButton(newReport(zl, 0, 0))
+	right.decodeButton(newReport(zr, 0, 0))
+	left.decodeButton(newReport(ButtonState{}, 0, 0))
+
+	assert.Equal(t, 3, len(pb.buttons))
+	assert.True(t, pb.buttons[0].Has(Button_L_ZL))
+	assert.True(t, pb.buttons[1].Has(Button_R_ZR))
+	assert.False(t, pb.buttons[1].Has(Button_L_ZL)) // only the new one is `down`
+	assert.True(t, pb.buttons[2].IsZero())          // ZL released, nothing goes down
+	assert.Equal(t, zr, p.merged)
+}
+
+func TestPairedHalfError(t *testing.T) {
+	left := newTestJoycon(SideLeft, nil)
+	right := newTestJoycon(SideRight, nil)
+
+	p := NewPaired(left, right)
+	pb := &probe{err: make(chan error, 1)}
+	p.Subscribe(pb)
+
+	left.emit(Event{Type: Event_ReadWriteError, Err: ErrStale})
+
+	var half *HalfError
+	e := <-pb.err
+	assert.True(t, errors.As(e, &half))
+	assert.Equal(t, Controller(left), half.Half)
+	assert.True(t, errors.Is(e, ErrStale))
+
+	// the other half can be used alone
+	p.Split()
+	rb := &probe{}
+	right.Subscribe(rb)
+	right.decodeButton(newReport(ButtonState{byte(Button_R_ZR)}, 0, 0))
+	assert.Equal(t, 1, len(rb.buttons))
+	assert.Equal(t, 0, len(pb.buttons))
+}
@@ -1,5 +1,11 @@
 package joycon
 
+import (
+	"math"
+	"sync"
+	"time"
+)
+
 // NOTE
 // ---- FBI WARNING ----
 // Don't use real maximum values for Amplitude. Otherwise, they can damage the linear actuators.
@@ -11,3 +17,162 @@ var RumbleFrequencyNeutral RumbleFrequency = [8]byte{0, 1, 0x40, 0x40, 0, 1, 0x4
 
 // a frequency sample that vibrates
 var RumbleFrequencySample RumbleFrequency = [8]byte{0, 4, 0x1, 0xfc, 0, 4, 0x01, 0xfc}
+
+const (
+	// Amplitude is clamped to this, the protocol allows ~1.0,
+	// leave some room for the actuators, see the warning above.
+	MaxRumbleAmplitude = 0.8
+
+	minHighFreq, maxHighFreq = 81.75177, 1252.572266 // Hz
+	minLowFreq, maxLowFreq   = 40.875885, 626.286133 // Hz
+)
+
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
+
+// frequency(Hz) -> 7bit log scale
+func encodeRumbleFreq(freq float64) int {
+	return int(math.Round(math.Log2(freq/10) * 32))
+}
+
+// amplitude(0~1) -> 7bit log scale, 0 ~ 100
+func encodeRumbleAmp(amp float64) int {
+	switch {
+	case amp <= 0:
+		return 0
+	case amp > 0.23:
+		return int(math.Round(math.Log2(amp*8.7) * 32))
+	case amp > 0.12:
+		return int(math.Round(math.Log2(amp*17) * 16))
+	default: // roughly linear in the lowest range
+		return int(math.Round(amp / 0.12 * 16))
+	}
+}
+
+// Encode the HD rumble data for one actuator,
+// it's a mix of 2 bands, high band: 81~1252Hz, low band: 40~626Hz,
+// amplitude is 0~1, clamped to `MaxRumbleAmplitude`.
+// ref: https://github.com/dekuNukem/Nintendo_Switch_Reverse_Engineering/blob/master/rumble_data_table.md
+func encodeRumble(highFreq, highAmp, lowFreq, lowAmp float64) [4]byte {
+	hf := (encodeRumbleFreq(clamp(highFreq, minHighFreq, maxHighFreq)) - 0x60) * 4 // 0x0004 ~ 0x01FC
+	lf := encodeRumbleFreq(clamp(lowFreq, minLowFreq, maxLowFreq)) - 0x40          // 0x01 ~ 0x7F
+
+	hfAmp := encodeRumbleAmp(clamp(highAmp, 0, MaxRumbleAmplitude)) * 2 // 0x00 ~ 0xC8
+
+	enc := encodeRumbleAmp(clamp(lowAmp, 0, MaxRumbleAmplitude))
+	lfAmp := enc/2 + 0x40 // 0x40 ~ 0x72
+	if enc%2 != 0 {
+		lfAmp |= 0x8000 // the odd bit goes to the MSB of byte 2
+	}
+
+	return [4]byte{
+		byte(hf & 0xFF),
+		byte(hfAmp + (hf>>8)&0xFF),
+		byte(lf + (lfAmp>>8)&0xFF),
+		byte(lfAmp & 0xFF),
+	}
+}
+
+// Same vibration for both the left and right actuator
+// (a Joy-Con only uses its own side, the Pro Controller uses both)
+func EncodeRumble(highFreq, highAmp, lowFreq, lowAmp float64) RumbleFrequency {
+	var r RumbleFrequency
+	side := encodeRumble(highFreq, highAmp, lowFreq, lowAmp)
+	copy(r[0:4], side[:])
+	copy(r[4:8], side[:])
+	return r
+}
+
+// One step of a vibration pattern
+type RumbleStep struct {
+	HighFreq, HighAmp float64
+	LowFreq, LowAmp   float64
+	Duration          time.Duration
+}
+
+func (s *RumbleStep) Encode() RumbleFrequency {
+	return EncodeRumble(s.HighFreq, s.HighAmp, s.LowFreq, s.LowAmp)
+}
+
+type RumblePattern []RumbleStep
+
+var rumblePause = RumbleStep{320, 0, 160, 0, 0}
+
+func pause(d time.Duration) RumbleStep {
+	p := rumblePause
+	p.Duration = d
+	return p
+}
+
+// Named patterns, for haptic feedback like `[rumble] -pattern tick`
+var RumblePatterns = map[string]RumblePattern{
+	"tick": {
+		{320, 0.5, 160, 0.3, 20 * time.Millisecond},
+	},
+	"double": {
+		{320, 0.6, 160, 0.4, 60 * time.Millisecond},
+		pause(80 * time.Millisecond),
+		{320, 0.6, 160, 0.4, 60 * time.Millisecond},
+	},
+	"error": {
+		{150, 0.7, 80, 0.7, 120 * time.Millisecond},
+		pause(60 * time.Millisecond),
+		{150, 0.7, 80, 0.7, 120 * time.Millisecond},
+		pause(60 * time.Millisecond),
+		{150, 0.7, 80, 0.7, 120 * time.Millisecond},
+	},
+	"connected": {
+		{640, 0.5, 320, 0.3, 100 * time.Millisecond},
+		{320, 0.6, 160, 0.4, 150 * time.Millisecond},
+	},
+}
+
+// The vibration fades if it's not refreshed, resend the same step at this interval.
+const rumbleRefresh = 50 * time.Millisecond
+
+// Plays one pattern at a time, a new one stops the previous.
+type rumblePlayer struct {
+	mu   sync.Mutex
+	stop chan struct{}
+}
+
+func (rp *rumblePlayer) play(send func(*RumbleFrequency) error, pattern RumblePattern) {
+	rp.mu.Lock()
+	if rp.stop != nil {
+		close(rp.stop)
+	}
+	stop := make(chan struct{})
+	rp.stop = stop
+	rp.mu.Unlock()
+
+	go func() {
+		for _, step := range pattern {
+			freq := step.Encode()
+			end := time.Now().Add(step.Duration)
+
+			for {
+				if e := send(&freq); e != nil {
+					return
+				}
+				left := time.Until(end)
+				if left <= 0 {
+					break
+				}
+				select {
+				case <-stop: // the new pattern takes over
+					return
+				case <-time.After(minDuration(left, rumbleRefresh)):
+				}
+			}
+		}
+		send(&RumbleFrequencyNeutral)
+	}()
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,62 @@
+package joycon
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeRumble(t *testing.T) {
+	// zero amplitude is the neutral frame
+	assert.Equal(t, RumbleFrequencyNeutral, EncodeRumble(320, 0, 160, 0))
+
+	// 320Hz/160Hz at max amplitude, clamped to the safe limit
+	r := EncodeRumble(320, 5, 160, 5)
+	assert.Equal(t, EncodeRumble(320, MaxRumbleAmplitude, 160, MaxRumbleAmplitude), r)
+	assert.Equal(t, r[0:4], r[4:8])
+	assert.Less(t, r[1]-1, byte(0xC8)) // high band amplitude
+	assert.Less(t, r[3], byte(0x72))   // low band amplitude
+
+	// out of range frequencies are clamped
+	assert.Equal(t, EncodeRumble(1252.572266, 0.5, 40.875885, 0.5), EncodeRumble(5000, 0.5, 1, 0.5))
+
+	// odd low band amplitude sets the MSB of byte 2
+	enc := encodeRumbleAmp(0.45)
+	assert.Equal(t, 1, enc%2)
+	assert.Equal(t, byte(0x80), EncodeRumble(320, 0, 160, 0.45)[2]&0x80)
+}
+
+func TestRumblePlayer(t *testing.T) {
+	var mu sync.Mutex
+	var sent []RumbleFrequency
+	send := func(f *RumbleFrequency) error {
+		mu.Lock()
+		sent = append(sent, *f)
+		mu.Unlock()
+		return nil
+	}
+
+	// both buzzes are played, each followed by the neutral frame
+	stops := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		n := 0
+		for i := 1; i < len(sent); i++ {
+			if sent[i] == RumbleFrequencyNeutral && sent[i-1] != RumbleFrequencyNeutral {
+				n++
+			}
+		}
+		return n
+	}
+
+	rp := &rumblePlayer{}
+	rp.play(send, RumblePatterns["double"])
+	assert.Eventually(t, func() bool { return stops() == 2 }, 2*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, RumblePatterns["double"][0].Encode(), sent[0])
+	assert.Equal(t, RumbleFrequencyNeutral, sent[len(sent)-1]) // stopped at the end
+}
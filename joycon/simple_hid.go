@@ -0,0 +1,107 @@
+package joycon
+
+import "math"
+
+/*
+* The 0x3F report, it's sent before switching to StandardFull mode
+* see: https://github.com/dekuNukem/Nintendo_Switch_Reverse_Engineering/blob/master/bluetooth_hid_notes.md#standard-input-report-format
+*   byte 1:     buttons, different for each controller
+*   byte 2:     buttons, mostly shared
+*   byte 3:     hat, the Joy-Con stick or the Pro Controller D-pad
+*   byte 4~11:  Pro Controller sticks, 16 bits for each axis
+ */
+
+// buttons of each bit in byte 1 and byte 2, 0 for unused
+type simpleLayout [2][8]ButtonID
+
+var simpleLayouts = map[JoyConSide]*simpleLayout{
+	SideLeft: {
+		{Button_L_Down, Button_L_Right, Button_L_Left, Button_L_Up, Button_L_SL, Button_L_SR},
+		{Button_Minus, Button_Plus, Button_L_Stick, Button_R_Stick, Button_Home, Button_Capture, Button_L_L, Button_L_ZL},
+	},
+	SideRight: {
+		{Button_R_A, Button_R_X, Button_R_B, Button_R_Y, Button_R_SL, Button_R_SR},
+		{Button_Minus, Button_Plus, Button_L_Stick, Button_R_Stick, Button_Home, Button_Capture, Button_R_R, Button_R_ZR},
+	},
+	SideBoth: {
+		{Button_R_B, Button_R_A, Button_R_Y, Button_R_X, Button_L_L, Button_R_R, Button_L_ZL, Button_R_ZR},
+		{Button_Minus, Button_Plus, Button_L_Stick, Button_R_Stick, Button_Home, Button_Capture},
+	},
+}
+
+// hat value 0~7 => Up, UpRight, ... clockwise, 8: neutral
+const simpleHatNeutral = 8
+
+var simpleHatRatios = [8]Ratio{
+	{0, 1}, {math.Sqrt2 / 2, math.Sqrt2 / 2}, {1, 0}, {math.Sqrt2 / 2, -math.Sqrt2 / 2},
+	{0, -1}, {-math.Sqrt2 / 2, -math.Sqrt2 / 2}, {-1, 0}, {-math.Sqrt2 / 2, math.Sqrt2 / 2},
+}
+
+// the Pro Controller D-pad, diagonals press 2 buttons
+var simpleHatDpad = [8][]ButtonID{
+	{Button_L_Up},
+	{Button_L_Up, Button_L_Right},
+	{Button_L_Right},
+	{Button_L_Down, Button_L_Right},
+	{Button_L_Down},
+	{Button_L_Down, Button_L_Left},
+	{Button_L_Left},
+	{Button_L_Up, Button_L_Left},
+}
+
+func (jc *joycon) decodeSimpleHid(packet []byte) {
+	layout, ok := simpleLayouts[jc.side]
+	if !ok || len(packet) < 12 {
+		return
+	}
+	defer jc.flush()
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	var buttons ButtonState
+	for i, bits := range layout {
+		for bit, id := range bits {
+			if id != 0 && packet[1+i]&(1<<bit) != 0 {
+				buttons.Set(id)
+			}
+		}
+	}
+	hat := packet[3]
+
+	var sticks [2]Ratio
+
+	switch jc.side {
+	// The hat is in the sideways orientation, pushing up when held sideways
+	// is pushing right(left Joy-Con) or left(right Joy-Con) when held vertically.
+	case SideLeft:
+		if hat < simpleHatNeutral {
+			sticks[0] = simpleHatRatios[(hat+2)%8]
+		}
+	case SideRight:
+		if hat < simpleHatNeutral {
+			sticks[1] = simpleHatRatios[(hat+6)%8]
+		}
+
+	case SideBoth:
+		if hat < simpleHatNeutral {
+			for _, id := range simpleHatDpad[hat] {
+				buttons.Set(id)
+			}
+		}
+		for i := range sticks {
+			sticks[i] = Ratio{
+				X: simpleAxis(packet[4+i*4:]),
+				Y: -simpleAxis(packet[6+i*4:]), // it grows downward
+			}
+		}
+	}
+
+	jc.updateButtons(buttons)
+	jc.updateSticks(sticks)
+}
+
+// 16 bits little endian, 0x8000 is center
+func simpleAxis(b []byte) float64 {
+	v := uint16(b[0]) | uint16(b[1])<<8
+	return (float64(v) - 0x8000) / 0x8000
+}
@@ -0,0 +1,68 @@
+package joycon
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSimpleReport(b1, b2, hat byte) []byte {
+	packet := make([]byte, 12)
+	packet[0] = SimpleHid
+	packet[1], packet[2], packet[3] = b1, b2, hat
+	for i := 4; i < 12; i += 2 { // sticks at center
+		packet[i+1] = 0x80
+	}
+	return packet
+}
+
+func TestSimpleHidRight(t *testing.T) {
+	p := &probe{}
+	jc := newTestJoycon(SideRight, p)
+
+	// A + ZR, stick at neutral
+	jc.decodeSimpleHid(newSimpleReport(0x01, 0x80, simpleHatNeutral))
+	assert.Equal(t, 1, len(p.buttons))
+	assert.True(t, p.buttons[0].Has(Button_R_A))
+	assert.True(t, p.buttons[0].Has(Button_R_ZR))
+	assert.Equal(t, 0, len(p.sticks))
+
+	// sideways up is left when held vertically
+	jc.decodeSimpleHid(newSimpleReport(0x01, 0x80, 0))
+	assert.Equal(t, 1, len(p.buttons)) // no change
+	assert.Equal(t, []Ratio{{-1, 0}}, p.sticks)
+
+	// back to neutral
+	jc.decodeSimpleHid(newSimpleReport(0, 0, simpleHatNeutral))
+	assert.Equal(t, 2, len(p.buttons))
+	assert.Equal(t, Ratio{}, p.sticks[1])
+}
+
+func TestSimpleHidLeft(t *testing.T) {
+	p := &probe{}
+	jc := newTestJoycon(SideLeft, p)
+
+	// Up + Minus, sideways down-right is down-left when held vertically
+	jc.decodeSimpleHid(newSimpleReport(0x08, 0x01, 3))
+	assert.True(t, p.buttons[0].Has(Button_L_Up))
+	assert.True(t, p.buttons[0].Has(Button_Minus))
+	assert.InDelta(t, -math.Sqrt2/2, p.sticks[0].X, 1e-9)
+	assert.InDelta(t, -math.Sqrt2/2, p.sticks[0].Y, 1e-9)
+}
+
+func TestSimpleHidPro(t *testing.T) {
+	p := &probe{}
+	jc := newTestJoycon(SideBoth, p)
+
+	// B, D-pad up-right, left stick pushed up
+	packet := newSimpleReport(0x01, 0, 1)
+	packet[6], packet[7] = 0, 0 // left Y, 0 is the top
+	jc.decodeSimpleHid(packet)
+
+	assert.True(t, p.buttons[0].Has(Button_R_B))
+	assert.True(t, p.buttons[0].Has(Button_L_Up))
+	assert.True(t, p.buttons[0].Has(Button_L_Right))
+	// the right stick stays at center, no event
+	assert.Equal(t, []Ratio{{0, 1}}, p.sticks)
+}
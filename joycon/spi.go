@@ -0,0 +1,201 @@
+package joycon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// ref: https://github.com/dekuNukem/Nintendo_Switch_Reverse_Engineering/blob/master/spi_flash_notes.md
+const (
+	flashSize     = 0x80000 // 512KB
+	maxSPIReadLen = 0x1D    // max bytes of each SPI read
+
+	serialStart = 0x6000 // ASCII, no serial number if the first byte >= 0x80
+	serialLen   = 16
+	colorStart  = 0x6050 // body, buttons, left grip, right grip, RGB each
+	colorLen    = 12
+)
+
+// A single controller that talks directly to the hardware,
+// the `Paired` one doesn't, use its `Left()`/`Right()`.
+type Device interface {
+	Controller
+
+	DeviceInfo() (*DeviceInfo, error)
+	SPIRead(addr uint32, length byte) ([]byte, error)
+	State() *State
+}
+
+// Reply of subcommand 0x02
+type DeviceInfo struct {
+	FirmwareMajor, FirmwareMinor byte
+	Type                         JoyConSide
+	Mac                          string
+	SPIColors                    bool // whether the colors in SPI are used
+}
+
+func (di *DeviceInfo) Firmware() string {
+	return fmt.Sprintf("%d.%02d", di.FirmwareMajor, di.FirmwareMinor)
+}
+
+func parseDeviceInfo(b []byte) (*DeviceInfo, error) {
+	// 2 bytes firmware, 1 byte type, 1 byte unknown, 6 bytes MAC, 1 byte unknown, 1 byte colors
+	if len(b) < 12 {
+		return nil, errors.New("device info too short")
+	}
+	return &DeviceInfo{
+		FirmwareMajor: b[0],
+		FirmwareMinor: b[1],
+		Type:          JoyConSide(b[2]),
+		Mac:           net.HardwareAddr(b[4:10]).String(),
+		SPIColors:     b[11] == 1,
+	}, nil
+}
+
+func (jc *joycon) DeviceInfo() (*DeviceInfo, error) {
+	reply, e := jc.subcommand([]byte{0x02})
+	if e != nil {
+		return nil, e
+	}
+	return parseDeviceInfo(reply)
+}
+
+// Reads SPI flash, from a controller, or a dumped image.
+type SPIReader func(addr uint32, length byte) ([]byte, error)
+
+// A dumped flash file
+type FlashImage []byte
+
+func (img FlashImage) Read(addr uint32, length byte) ([]byte, error) {
+	end := int(addr) + int(length)
+	if end > len(img) {
+		return nil, fmt.Errorf("SPI read 0x%X: out of image", addr)
+	}
+	return img[addr:end], nil
+}
+
+// Read the whole flash and write it to `w`, in chunks,
+// `progress` is called after each chunk, can be nil.
+// It's read-only, nothing is written to the controller.
+func DumpSPI(read SPIReader, w io.Writer, progress func(done, total int)) error {
+	for addr := 0; addr < flashSize; addr += maxSPIReadLen {
+		length := maxSPIReadLen
+		if addr+length > flashSize {
+			length = flashSize - addr
+		}
+		data, e := read(uint32(addr), byte(length))
+		if e != nil {
+			return e
+		}
+		if _, e = w.Write(data); e != nil {
+			return e
+		}
+		if progress != nil {
+			progress(addr+length, flashSize)
+		}
+	}
+	return nil
+}
+
+type Color struct {
+	R, G, B byte
+}
+
+func (c Color) String() string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// The known regions of SPI flash
+type FlashInfo struct {
+	Serial string // empty if not set
+
+	Body, Buttons       Color
+	LeftGrip, RightGrip Color // only for the Pro Controller
+
+	FactoryStick [2]CalibrationData
+	UserStick    [2]*CalibrationData // nil if not set
+	FactoryImu   ImuCalibration
+	UserImu      *ImuCalibration // nil if not set
+}
+
+func ParseSerial(b []byte) string {
+	if len(b) == 0 || b[0] >= 0x80 {
+		return ""
+	}
+	return strings.TrimRight(string(b), "\x00 ")
+}
+
+func ParseColors(b []byte) (body, buttons, leftGrip, rightGrip Color) {
+	c := func(i int) Color { return Color{b[i], b[i+1], b[i+2]} }
+	return c(0), c(3), c(6), c(9)
+}
+
+// factory stick calibration: left 9 bytes, right 9 bytes
+func ParseFactoryStick(b []byte) (ret [2]CalibrationData) {
+	ret[0].Parse(b[0:9], SideLeft)
+	ret[1].Parse(b[9:18], SideRight)
+	return
+}
+
+// user stick calibration: for each side, 2 bytes magic + 9 bytes
+func ParseUserStick(b []byte) (ret [2]*CalibrationData) {
+	for i, side := range []JoyConSide{SideLeft, SideRight} {
+		off := i * 11
+		if binary.LittleEndian.Uint16(b[off:off+2]) == magicHaveCalibration {
+			ret[i] = &CalibrationData{}
+			ret[i].Parse(b[off+2:off+11], side)
+		}
+	}
+	return
+}
+
+// user IMU calibration: 2 bytes magic + 24 bytes
+func ParseUserImu(b []byte) *ImuCalibration {
+	if binary.LittleEndian.Uint16(b[0:2]) != magicHaveCalibration {
+		return nil
+	}
+	c := &ImuCalibration{}
+	c.Parse(b[2:])
+	return c
+}
+
+func ReadFlashInfo(read SPIReader) (*FlashInfo, error) {
+	info := &FlashInfo{}
+
+	b, e := read(serialStart, serialLen)
+	if e != nil {
+		return nil, e
+	}
+	info.Serial = ParseSerial(b)
+
+	if b, e = read(colorStart, colorLen); e != nil {
+		return nil, e
+	}
+	info.Body, info.Buttons, info.LeftGrip, info.RightGrip = ParseColors(b)
+
+	if b, e = read(factoryStickCalibStart, factoryStickCalibLen); e != nil {
+		return nil, e
+	}
+	info.FactoryStick = ParseFactoryStick(b)
+
+	if b, e = read(userStickCalibStart, userStickCalibLen); e != nil {
+		return nil, e
+	}
+	info.UserStick = ParseUserStick(b)
+
+	if b, e = read(factoryImuCalibStart, factoryImuCalibLen); e != nil {
+		return nil, e
+	}
+	info.FactoryImu.Parse(b)
+
+	if b, e = read(userImuCalibStart, userImuCalibLen); e != nil {
+		return nil, e
+	}
+	info.UserImu = ParseUserImu(b)
+
+	return info, nil
+}
@@ -0,0 +1,61 @@
+package joycon
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeviceInfo(t *testing.T) {
+	di, e := parseDeviceInfo([]byte{
+		0x03, 0x49, 0x02, 0x02, 0x70, 0x48, 0xF7, 0x76, 0xBC, 0x87, 0x01, 0x01,
+	})
+	assert.Nil(t, e)
+	assert.Equal(t, "3.73", di.Firmware())
+	assert.Equal(t, JoyConSide(SideRight), di.Type)
+	assert.Equal(t, "70:48:f7:76:bc:87", di.Mac)
+	assert.True(t, di.SPIColors)
+
+	_, e = parseDeviceInfo([]byte{0x03})
+	assert.NotNil(t, e)
+}
+
+func TestFlashInfo(t *testing.T) {
+	img := make(FlashImage, flashSize)
+	for i := range img {
+		img[i] = 0xFF // erased
+	}
+	copy(img[serialStart:], "XCW12345678901\x00\x00")
+	copy(img[colorStart:], []byte{0x0A, 0xB9, 0xE6, 0x00, 0x1E, 0x1E})
+
+	// factory stick from the calibration reply
+	copy(img[factoryStickCalibStart:], newCalibReply()[20:20+18])
+	// only the right stick has user calibration
+	copy(img[userStickCalibStart+11:], []byte{0xB2, 0xA1})
+	copy(img[userStickCalibStart+13:], encodeUint12(0x7F0, 0x810))
+
+	info, e := ReadFlashInfo(img.Read)
+	assert.Nil(t, e)
+	assert.Equal(t, "XCW12345678901", info.Serial)
+	assert.Equal(t, "#0AB9E6", info.Body.String())
+	assert.Equal(t, "#001E1E", info.Buttons.String())
+	assert.Equal(t, [2]CalibrationData{
+		{0x500, 0x800, 0x500, 0x500, 0x800, 0x500},
+		{0x500, 0x800, 0x500, 0x500, 0x800, 0x500},
+	}, info.FactoryStick)
+	assert.Nil(t, info.UserStick[0])
+	assert.Equal(t, uint16(0x7F0), info.UserStick[1].xCenter)
+	assert.Nil(t, info.UserImu)
+
+	// no serial number
+	assert.Equal(t, "", ParseSerial([]byte{0xFF, 0xFF}))
+
+	// dump it all
+	buf := &bytes.Buffer{}
+	chunks := 0
+	e = DumpSPI(img.Read, buf, func(done, total int) { chunks++ })
+	assert.Nil(t, e)
+	assert.Equal(t, []byte(img), buf.Bytes())
+	assert.Equal(t, (flashSize+maxSPIReadLen-1)/maxSPIReadLen, chunks)
+}
@@ -0,0 +1,108 @@
+package joycon
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// A controller is considered disconnected if no report is received for this long,
+// 0 to disable.
+// Only checked in StandardFull mode, the 0x3F reports are only sent on change.
+var StaleTimeout = 3 * time.Second
+
+const watchdogInterval = 500 * time.Millisecond
+
+var ErrStale = errors.New("no report received, connection stalled")
+
+// What's known about a controller, saved when it disconnects
+// and restored when it reconnects, so it works right away.
+type State struct {
+	ReportMode   byte
+	StickCalib   [2]CalibrationData
+	ImuCalib     ImuCalibration
+	ImuUserCalib bool
+	GyroOn       bool
+	Lights       byte
+	LightsOn     bool
+	HomeLight    *HomeLight
+}
+
+func (jc *joycon) State() *State {
+	jc.mu.RLock()
+	defer jc.mu.RUnlock()
+
+	return &State{
+		ReportMode:   jc.reportMode,
+		StickCalib:   jc.stickCalib,
+		ImuCalib:     jc.imuCalib,
+		ImuUserCalib: jc.imuUserCalib,
+		GyroOn:       jc.gyroOn,
+		Lights:       jc.lights,
+		LightsOn:     jc.lightsOn,
+		HomeLight:    jc.homeLight,
+	}
+}
+
+// apply the saved state, only the missing calibration is read from SPI
+func (jc *joycon) restore(state *State) {
+	jc.mu.Lock()
+	jc.stickCalib = state.StickCalib
+	jc.imuCalib, jc.imuUserCalib = state.ImuCalib, state.ImuUserCalib
+	jc.mu.Unlock()
+
+	if !jc.isCalibrated() {
+		if e := jc.CalibrateStick(); e != nil {
+			log.Errorf("%s: fail to calibrate stick: %s", jc.Mac(), e.Error())
+		}
+	}
+	if !jc.isImuCalibrated() {
+		if e := jc.CalibrateIMU(); e != nil {
+			log.Errorf("%s: fail to calibrate IMU: %s", jc.Mac(), e.Error())
+		}
+	}
+	if state.GyroOn {
+		if e := jc.EnableGyro(true); e != nil {
+			log.Errorf("%s: fail to enable gyro: %s", jc.Mac(), e.Error())
+		}
+	}
+	if state.LightsOn {
+		if e := jc.SetLights(state.Lights); e != nil {
+			log.Errorf("%s: fail to set lights: %s", jc.Mac(), e.Error())
+		}
+	}
+	if state.HomeLight != nil {
+		if e := jc.SetHomeLight(state.HomeLight); e != nil {
+			log.Errorf("%s: fail to set HOME light: %s", jc.Mac(), e.Error())
+		}
+	}
+	log.Debugf("%s: state restored", jc.Mac())
+}
+
+// Report `ErrStale` to the listener if the reports stop coming,
+// the read never fails on a stalled connection.
+func (jc *joycon) watchdog() {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		jc.mu.RLock()
+		closed := jc.transport == nil
+		fullMode := jc.reportMode == StandardFull
+		jc.mu.RUnlock()
+
+		if closed {
+			return
+		}
+		if StaleTimeout <= 0 || !fullMode {
+			continue
+		}
+		silence := time.Since(time.Unix(0, jc.lastReport.Load()))
+		if silence > StaleTimeout {
+			log.Warningf("%s: no report for %s", jc.Mac(), silence.Round(time.Millisecond))
+			jc.emit(Event{Type: Event_ReadWriteError, Err: ErrStale})
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package joycon
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestoreState(t *testing.T) {
+	settle := setupSettle
+	setupSettle = 0
+	defer func() { setupSettle = settle }()
+
+	var mu sync.Mutex
+	subs := []byte{}
+
+	tr := newReplyTransport(func(sub []byte) []byte {
+		mu.Lock()
+		subs = append(subs, sub[0])
+		mu.Unlock()
+		return newSubcommandReply(0x80, sub[0], nil)
+	})
+	tr.reports <- newReport(ButtonState{}, 0x800, 0x800) // ready
+
+	calib := [2]CalibrationData{{}, {0x800, 0x800, 0x500, 0x500, 0x500, 0x500}}
+	jc := RestoreJoycon(tr, SideRight, "mac", &State{
+		ReportMode: StandardFull,
+		StickCalib: calib,
+		ImuCalib:   ImuCalibration{accCoeff: [3]int16{1, 1, 1}},
+		GyroOn:     true,
+		Lights:     0x01,
+		LightsOn:   true,
+		HomeLight:  HomeLightPatterns["pulse"],
+	}).(*joycon)
+	defer jc.Disconnect()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(subs) >= 4
+	}, time.Second, 10*time.Millisecond)
+
+	// no SPI read, the calibration is restored
+	mu.Lock()
+	assert.Equal(t, []byte{0x03, 0x40, 0x30, 0x38}, subs[:4])
+	mu.Unlock()
+
+	st := jc.State()
+	assert.Equal(t, calib, st.StickCalib)
+	assert.True(t, st.GyroOn)
+	assert.Equal(t, byte(StandardFull), st.ReportMode)
+	assert.Equal(t, HomeLightPatterns["pulse"], st.HomeLight)
+}
+
+type staleProbe struct {
+	probe
+	stale chan error
+}
+
+func (p *staleProbe) OnReadWriteError(jc Controller, e error) {
+	p.stale <- e
+}
+
+func TestWatchdog(t *testing.T) {
+	timeout := StaleTimeout
+	StaleTimeout = 100 * time.Millisecond
+	defer func() { StaleTimeout = timeout }()
+
+	p := &staleProbe{stale: make(chan error, 1)}
+	jc := newTestJoycon(SideRight, nil)
+	jc.Subscribe(p)
+	jc.transport = newReplyTransport(func([]byte) []byte { return nil })
+	jc.lastReport.Store(time.Now().UnixNano())
+	go jc.watchdog()
+	defer jc.Disconnect()
+
+	// not in full mode, the 0x3F reports only come on change
+	select {
+	case <-p.stale:
+		t.Fatal("stale before entering full mode")
+	case <-time.After(2 * watchdogInterval):
+	}
+
+	jc.mu.Lock()
+	jc.reportMode = StandardFull
+	jc.mu.Unlock()
+
+	select {
+	case e := <-p.stale:
+		assert.Equal(t, ErrStale, e)
+	case <-time.After(2 * time.Second):
+		t.Fatal("stale connection not detected")
+	}
+}
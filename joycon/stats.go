@@ -0,0 +1,136 @@
+package joycon
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The report timer normally increases by 3 between 2 reports(15ms),
+// larger steps mean reports are lost on the way
+const reportTicks = 3
+
+// Statistics of input reports, tells whether the Bluetooth or
+// the handlers are the cause when the cursor stutters.
+type ReportStats struct {
+	Since    time.Time // when counting starts
+	Reports  uint64    // input reports with timer, 0x21 and 0x30
+	Repeated uint64    // same timer as the previous one
+	Gaps     uint64    // times of reports lost
+	Lost     uint64    // total reports lost, by the timer
+
+	IntervalMax time.Duration // max time between 2 reports received
+	HandleMax   time.Duration // max time spent on a report, mostly by the listener
+
+	intervals   uint64
+	intervalSum time.Duration
+	handleSum   time.Duration
+}
+
+// average time between 2 reports received
+func (s *ReportStats) IntervalAvg() time.Duration {
+	if s.intervals == 0 {
+		return 0
+	}
+	return s.intervalSum / time.Duration(s.intervals)
+}
+
+// average time spent on a report
+func (s *ReportStats) HandleAvg() time.Duration {
+	if s.Reports == 0 {
+		return 0
+	}
+	return s.handleSum / time.Duration(s.Reports)
+}
+
+// lost / expected, 0~1
+func (s *ReportStats) LossRate() float64 {
+	if s.Reports+s.Lost == 0 {
+		return 0
+	}
+	return float64(s.Lost) / float64(s.Reports+s.Lost)
+}
+
+func (s *ReportStats) String() string {
+	return fmt.Sprintf(
+		"reports: %d, lost: %d(%.2f%%) in %d gaps, repeated: %d, interval: avg %s max %s, handler: avg %s max %s",
+		s.Reports, s.Lost, s.LossRate()*100, s.Gaps, s.Repeated,
+		s.IntervalAvg(), s.IntervalMax, s.HandleAvg(), s.HandleMax)
+}
+
+// combine the stats of 2 controllers, the Paired one
+func (s *ReportStats) merge(o *ReportStats) {
+	if s.Since.IsZero() || (!o.Since.IsZero() && o.Since.Before(s.Since)) {
+		s.Since = o.Since
+	}
+	s.Reports += o.Reports
+	s.Repeated += o.Repeated
+	s.Gaps += o.Gaps
+	s.Lost += o.Lost
+	if o.IntervalMax > s.IntervalMax {
+		s.IntervalMax = o.IntervalMax
+	}
+	if o.HandleMax > s.HandleMax {
+		s.HandleMax = o.HandleMax
+	}
+	s.intervals += o.intervals
+	s.intervalSum += o.intervalSum
+	s.handleSum += o.handleSum
+}
+
+type statsTracker struct {
+	mu    sync.Mutex
+	stats ReportStats
+	timer byte      // of the previous report
+	last  time.Time // when the previous report is received, zero before the first
+}
+
+// `recv` is when the report is received, `handle` is the time spent on it
+func (t *statsTracker) record(timer byte, recv time.Time, handle time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := &t.stats
+	if t.last.IsZero() {
+		if s.Since.IsZero() {
+			s.Since = recv
+		}
+	} else {
+		interval := recv.Sub(t.last)
+		s.intervals++
+		s.intervalSum += interval
+		if interval > s.IntervalMax {
+			s.IntervalMax = interval
+		}
+
+		ticks := timer - t.timer // it wraps around
+		if ticks == 0 {
+			s.Repeated++
+		} else if lost := (uint64(ticks) + reportTicks/2) / reportTicks; lost > 1 {
+			s.Gaps++
+			s.Lost += lost - 1
+		}
+	}
+	t.timer, t.last = timer, recv
+
+	s.Reports++
+	s.handleSum += handle
+	if handle > s.HandleMax {
+		s.HandleMax = handle
+	}
+}
+
+func (t *statsTracker) snapshot() ReportStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.stats
+}
+
+func (t *statsTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stats = ReportStats{}
+	t.last = time.Time{}
+}
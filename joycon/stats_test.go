@@ -0,0 +1,47 @@
+package joycon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportStats(t *testing.T) {
+	tr := &statsTracker{}
+	now := time.Now()
+
+	// timer, ms since the previous one, handler ms
+	for _, r := range [][3]int{
+		{0xFA, 0, 1},
+		{0xFD, 15, 1},
+		{0x00, 15, 2}, // wraps around
+		{0x00, 15, 1}, // repeated
+		{0x09, 45, 1}, // 2 lost
+		{0x0C, 15, 7}, // slow handler
+		{0x18, 60, 1}, // 3 lost
+	} {
+		now = now.Add(time.Duration(r[1]) * time.Millisecond)
+		tr.record(byte(r[0]), now, time.Duration(r[2])*time.Millisecond)
+	}
+	s := tr.snapshot()
+	assert.Equal(t, uint64(7), s.Reports)
+	assert.Equal(t, uint64(1), s.Repeated)
+	assert.Equal(t, uint64(2), s.Gaps)
+	assert.Equal(t, uint64(5), s.Lost)
+	assert.InDelta(t, 5.0/12, s.LossRate(), 1e-9)
+	assert.Equal(t, 60*time.Millisecond, s.IntervalMax)
+	assert.Equal(t, 27500*time.Microsecond, s.IntervalAvg()) // 165ms / 6
+	assert.Equal(t, 7*time.Millisecond, s.HandleMax)
+	assert.Equal(t, 2*time.Millisecond, s.HandleAvg())
+
+	// merged with another controller
+	other := ReportStats{Reports: 3, Lost: 1, HandleMax: 9 * time.Millisecond}
+	s.merge(&other)
+	assert.Equal(t, uint64(10), s.Reports)
+	assert.Equal(t, uint64(6), s.Lost)
+	assert.Equal(t, 9*time.Millisecond, s.HandleMax)
+
+	tr.reset()
+	assert.Equal(t, ReportStats{}, tr.snapshot())
+}
@@ -1,6 +1,7 @@
 package joycon
 
 import (
+	"errors"
 	"fmt"
 	"math"
 )
@@ -26,6 +27,14 @@ const (
 	Spin_Down_Leave
 	Spin_Left
 	Spin_Left_Leave
+	Spin_UpLeft
+	Spin_UpLeft_Leave
+	Spin_UpRight
+	Spin_UpRight_Leave
+	Spin_DownLeft
+	Spin_DownLeft_Leave
+	Spin_DownRight
+	Spin_DownRight_Leave
 )
 
 var SpinDirectionMap = map[string]SpinDirection{
@@ -39,12 +48,28 @@ var SpinDirectionMap = map[string]SpinDirection{
 	"LeftLeave":    Spin_Left_Leave,
 	"Neutral":      Spin_Neutral,
 	"NeutralLeave": Spin_Neutral_Leave,
+
+	"UpLeft":         Spin_UpLeft,
+	"UpLeftLeave":    Spin_UpLeft_Leave,
+	"UpRight":        Spin_UpRight,
+	"UpRightLeave":   Spin_UpRight_Leave,
+	"DownLeft":       Spin_DownLeft,
+	"DownLeftLeave":  Spin_DownLeft_Leave,
+	"DownRight":      Spin_DownRight,
+	"DownRightLeave": Spin_DownRight_Leave,
 }
 var ReverseDirectionMap = map[SpinDirection]SpinDirection{
 	Spin_Up:    Spin_Up_Leave,
 	Spin_Right: Spin_Right_Leave,
 	Spin_Down:  Spin_Down_Leave,
 	Spin_Left:  Spin_Left_Leave,
+
+	Spin_UpLeft:    Spin_UpLeft_Leave,
+	Spin_UpRight:   Spin_UpRight_Leave,
+	Spin_DownLeft:  Spin_DownLeft_Leave,
+	Spin_DownRight: Spin_DownRight_Leave,
+
+	Spin_Neutral: Spin_Neutral_Leave,
 }
 
 type Axis2D[T uint16 | float64] struct {
@@ -62,22 +87,6 @@ func (r *Ratio) AtNeutral() bool {
 	return math.Abs(r.X) <= SpinNeutralThreshold && math.Abs(r.Y) <= SpinNeutralThreshold
 }
 
-// if the Ratio is at edge
-// meaning it exceeds the `SpinEdgeThreshhold` in that direction
-func (r *Ratio) AtEdge(dir SpinDirection) bool {
-	switch dir {
-	case Spin_Up:
-		return r.Y >= SpinEdgeThreshhold
-	case Spin_Down:
-		return -r.Y >= SpinEdgeThreshhold
-	case Spin_Left:
-		return -r.X >= SpinEdgeThreshhold
-	case Spin_Right:
-		return r.X >= SpinEdgeThreshhold
-	}
-	return false
-}
-
 func decodeUint12(b []byte) (uint16, uint16) {
 	d1 := uint16(b[0]) | (uint16(b[1]&0xF) << 8)
 	d2 := uint16(b[1]>>4) | (uint16(b[2]) << 4)
@@ -104,6 +113,11 @@ func (c *CalibrationData) Parse(b []byte, side JoyConSide) {
 	}
 }
 
+func (c CalibrationData) String() string {
+	return fmt.Sprintf("center(%d, %d) x: -%d~+%d y: -%d~+%d",
+		c.xCenter, c.yCenter, c.xMinOff, c.xMaxOff, c.yMinOff, c.yMaxOff)
+}
+
 // Transform raw stick values into -1.0~1.0 float64.
 // 0 == center, -1.0 == most left, 1.0 == most right
 func (c *CalibrationData) Adjust(rawXY *Point) (ret Ratio) {
@@ -124,3 +138,226 @@ func (c *CalibrationData) Adjust(rawXY *Point) (ret Ratio) {
 	}
 	return
 }
+
+// ---- 8-way directions ----
+
+var (
+	// Once in a direction, it stays until the stick is this much(ratio) beyond the threshold,
+	// so it doesn't flicker at the boundary
+	DirectionHysteresis float64 = 0.05
+	// same for the angle, in degrees, each sector is 45 degrees
+	SectorHysteresis float64 = 5
+)
+
+// counter-clockwise, from the right(0 degree), each one is 45 degrees
+var sectorDirections = [8]SpinDirection{
+	Spin_Right, Spin_UpRight, Spin_Up, Spin_UpLeft,
+	Spin_Left, Spin_DownLeft, Spin_Down, Spin_DownRight,
+}
+
+// angle of the ratio in degrees, 0: right, 90: up
+func ratioAngle(r *Ratio) float64 {
+	return math.Atan2(r.Y, r.X) * 180 / math.Pi
+}
+
+// which sector the angle falls in
+func sectorOf(angle float64) int {
+	return int(math.Round(angle/45)+8) % 8
+}
+
+// absolute difference between 2 angles, 0~180
+func angleDiff(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	return math.Min(d, 360-d)
+}
+
+// Tracks which of the 8 directions the stick is pointing to, with hysteresis.
+type DirectionTracker struct {
+	// Spin_Neutral, one of the 8 directions at the edge,
+	// or SpinDirection_None when it's between neutral and edge
+	dir    SpinDirection
+	sector int // index of `sectorDirections`, valid when it's at the edge
+}
+
+// The stick is at neutral when attached
+func NewDirectionTracker() *DirectionTracker {
+	return &DirectionTracker{dir: Spin_Neutral}
+}
+
+func (t *DirectionTracker) Direction() SpinDirection {
+	return t.dir
+}
+
+// Returns the events of the change, the leave event of the previous direction first,
+// then the enter event of the new one. Nothing if it stays.
+func (t *DirectionTracker) Update(r *Ratio) (events []SpinDirection) {
+	dir, sector := t.next(r)
+	if dir == t.dir && sector == t.sector {
+		return nil
+	}
+	if t.dir != SpinDirection_None {
+		events = append(events, ReverseDirectionMap[t.dir])
+	}
+	if dir != SpinDirection_None {
+		events = append(events, dir)
+	}
+	t.dir, t.sector = dir, sector
+	return
+}
+
+func (t *DirectionTracker) next(r *Ratio) (SpinDirection, int) {
+	dist := math.Hypot(r.X, r.Y)
+	angle := ratioAngle(r)
+
+	// stays if it's still in the range with hysteresis
+	switch t.dir {
+	case SpinDirection_None:
+	case Spin_Neutral:
+		if dist <= SpinNeutralThreshold+DirectionHysteresis {
+			return t.dir, t.sector
+		}
+	default:
+		if dist >= SpinEdgeThreshhold-DirectionHysteresis &&
+			angleDiff(angle, float64(t.sector)*45) <= 22.5+SectorHysteresis {
+			return t.dir, t.sector
+		}
+	}
+
+	switch {
+	case dist <= SpinNeutralThreshold:
+		return Spin_Neutral, 0
+	case dist >= SpinEdgeThreshhold:
+		sector := sectorOf(angle)
+		return sectorDirections[sector], sector
+	}
+	return SpinDirection_None, 0
+}
+
+// ---- response ----
+
+// Deadzone shapes
+const (
+	Deadzone_Axial        = "axial"         // each axis is zeroed separately, it snaps to the axes
+	Deadzone_Radial       = "radial"        // zeroed if the distance to center is small
+	Deadzone_ScaledRadial = "scaled_radial" // radial, and rescaled, so there is no jump at the edge of deadzone
+)
+
+// Response curves
+const (
+	Curve_Linear = "linear"
+	Curve_Power  = "power"  // x^Exponent, >1 for finer positioning near center
+	Curve_SCurve = "scurve" // slow near center and edge, fast in between
+	Curve_Points = "points" // linear interpolation between `Points`
+)
+
+// The stick processing, applied to the calibrated ratio before `OnStick`,
+// the zero value changes nothing.
+type StickResponse struct {
+	Shape         string       `comment:"deadzone shape: axial, radial, scaled_radial"`
+	Deadzone      float64      `comment:"inner deadzone, the stick is neutral inside it (range: 0~1.0)"`
+	OuterDeadzone float64      `comment:"the stick is considered at the edge when it's this close to the edge (range: 0~1.0)"`
+	AntiDeadzone  float64      `comment:"the output jumps to this when leaving the deadzone, for games that have their own deadzone (range: 0~1.0)"`
+	Curve         string       `comment:"response curve: linear, power, scurve, points"`
+	Exponent      float64      `comment:"for curve 'power' and 'scurve', e.g. 2"`
+	Points        [][2]float64 `comment:"for curve 'points', [input, output] pairs between 0 and 1, e.g. [[0.5, 0.2], [0.8, 0.5]]"`
+}
+
+// Applied to all controllers, [left stick, right stick], nil for none
+var StickResponses [2]*StickResponse
+
+func (r *StickResponse) Check() error {
+	switch r.Shape {
+	case "", Deadzone_Axial, Deadzone_Radial, Deadzone_ScaledRadial:
+	default:
+		return fmt.Errorf("invalid deadzone shape: %s", r.Shape)
+	}
+	for _, v := range []float64{r.Deadzone, r.OuterDeadzone, r.AntiDeadzone} {
+		if v < 0 || v >= 1 {
+			return fmt.Errorf("deadzone %f out of range 0~1", v)
+		}
+	}
+	if r.Deadzone+r.OuterDeadzone >= 1 {
+		return errors.New("no room left between inner and outer deadzone")
+	}
+
+	switch r.Curve {
+	case "", Curve_Linear:
+	case Curve_Power, Curve_SCurve:
+		if r.Exponent <= 0 {
+			return fmt.Errorf("curve %s needs a positive exponent", r.Curve)
+		}
+	case Curve_Points:
+		prev := 0.0
+		for _, p := range r.Points {
+			if p[0] <= prev || p[0] >= 1 || p[1] < 0 || p[1] > 1 {
+				return fmt.Errorf("invalid curve point: %v, input should increase within 0~1", p)
+			}
+			prev = p[0]
+		}
+	default:
+		return fmt.Errorf("invalid curve: %s", r.Curve)
+	}
+	return nil
+}
+
+// map the distance 0~1 through the curve
+func (r *StickResponse) curve(x float64) float64 {
+	switch r.Curve {
+	case Curve_Power:
+		return math.Pow(x, r.Exponent)
+	case Curve_SCurve:
+		if x < 0.5 {
+			return math.Pow(2*x, r.Exponent) / 2
+		}
+		return 1 - math.Pow(2*(1-x), r.Exponent)/2
+	case Curve_Points:
+		x0, y0 := 0.0, 0.0
+		for _, p := range append(r.Points, [2]float64{1, 1}) {
+			if x <= p[0] {
+				return y0 + (x-x0)/(p[0]-x0)*(p[1]-y0)
+			}
+			x0, y0 = p[0], p[1]
+		}
+		return 1
+	}
+	return x
+}
+
+func (r *StickResponse) Apply(ratio *Ratio) {
+	x, y := ratio.X, ratio.Y
+
+	dist := math.Hypot(x, y)
+	switch r.Shape {
+	case Deadzone_Axial:
+		if math.Abs(x) < r.Deadzone {
+			x = 0
+		}
+		if math.Abs(y) < r.Deadzone {
+			y = 0
+		}
+		dist = math.Hypot(x, y)
+	case Deadzone_ScaledRadial:
+		if dist < r.Deadzone {
+			dist = 0
+		} else {
+			dist = (dist - r.Deadzone) / (1 - r.Deadzone)
+		}
+	default: // radial
+		if dist < r.Deadzone {
+			dist = 0
+		}
+	}
+	if dist == 0 {
+		*ratio = NeutralRatio
+		return
+	}
+
+	dist = math.Min(1, dist/(1-r.OuterDeadzone))
+	dist = r.curve(dist)
+	dist = r.AntiDeadzone + (1-r.AntiDeadzone)*dist
+
+	// same direction, new distance
+	scale := dist / math.Hypot(x, y)
+	ratio.X = x * scale
+	ratio.Y = y * scale
+}
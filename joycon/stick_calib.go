@@ -0,0 +1,124 @@
+package joycon
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// the stick must be rotated at least this far(raw value) from center on each direction,
+	// the factory range is ~0x500~0x600
+	minStickTravel = 0x200
+
+	// the deadzone is a bit larger than the noise when resting
+	restNoiseMargin = 1.5
+)
+
+// The stick range measured on the host, in raw values,
+// it's preferred over the factory calibration, for worn sticks.
+type StickRange struct {
+	Min, Center, Max Point
+	Deadzone         float64 // 0~1, the ratio is zero inside this radius
+}
+
+func (r *StickRange) Calibration() CalibrationData {
+	return CalibrationData{
+		xMinOff: r.Center.X - r.Min.X,
+		xCenter: r.Center.X,
+		xMaxOff: r.Max.X - r.Center.X,
+		yMinOff: r.Center.Y - r.Min.Y,
+		yCenter: r.Center.Y,
+		yMaxOff: r.Max.Y - r.Center.Y,
+	}
+}
+
+func (r *StickRange) String() string {
+	c := r.Calibration()
+	return fmt.Sprintf("%s deadzone: %.3f", c.String(), r.Deadzone)
+}
+
+// zero inside the deadzone
+func (r *StickRange) Apply(ratio *Ratio) {
+	if math.Hypot(ratio.X, ratio.Y) < r.Deadzone {
+		*ratio = NeutralRatio
+	}
+}
+
+// Collects raw samples of one stick for `StickRange`,
+// first rotate it to the edge, then rest it at center.
+type StickSampler struct {
+	min, max Point
+	hasRange bool
+	rest     []Point
+}
+
+// sample while rotating
+func (s *StickSampler) AddRange(p Point) {
+	if !s.hasRange {
+		s.min, s.max = p, p
+		s.hasRange = true
+		return
+	}
+	s.min.X, s.max.X = minU16(s.min.X, p.X), maxU16(s.max.X, p.X)
+	s.min.Y, s.max.Y = minU16(s.min.Y, p.Y), maxU16(s.max.Y, p.Y)
+}
+
+// sample while resting
+func (s *StickSampler) AddRest(p Point) {
+	s.rest = append(s.rest, p)
+}
+
+func (s *StickSampler) Result() (*StickRange, error) {
+	if !s.hasRange {
+		return nil, errors.New("stick not rotated")
+	}
+	if len(s.rest) == 0 {
+		return nil, errors.New("no resting samples")
+	}
+	var sumX, sumY float64
+	for _, p := range s.rest {
+		sumX += float64(p.X)
+		sumY += float64(p.Y)
+	}
+	n := float64(len(s.rest))
+	r := &StickRange{
+		Min:    s.min,
+		Max:    s.max,
+		Center: Point{X: uint16(math.Round(sumX / n)), Y: uint16(math.Round(sumY / n))},
+	}
+	// the center may be out of the range if it's not rotated at all
+	travel := []int{
+		int(r.Center.X) - int(r.Min.X), int(r.Max.X) - int(r.Center.X),
+		int(r.Center.Y) - int(r.Min.Y), int(r.Max.Y) - int(r.Center.Y),
+	}
+	for _, t := range travel {
+		if t < minStickTravel {
+			return nil, fmt.Errorf("stick not rotated to the edge, travel: %v", travel)
+		}
+	}
+
+	// the noise when resting
+	calib := r.Calibration()
+	noise := 0.0
+	for i := range s.rest {
+		ratio := calib.Adjust(&s.rest[i])
+		noise = math.Max(noise, math.Hypot(ratio.X, ratio.Y))
+	}
+	r.Deadzone = noise * restNoiseMargin
+
+	return r, nil
+}
+
+func minU16(a, b uint16) uint16 {
+	if a < b {
+		return a
+	}
+	return b
+}
+func maxU16(a, b uint16) uint16 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,59 @@
+package joycon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStickSampler(t *testing.T) {
+	s := &StickSampler{}
+
+	_, e := s.Result()
+	assert.NotNil(t, e)
+
+	// worn stick, only reaches 0x400 from center
+	for _, p := range []Point{
+		{0x800, 0xC00}, {0x400, 0x800}, {0x800, 0x400}, {0xC00, 0x800},
+	} {
+		s.AddRange(p)
+	}
+	for _, p := range []Point{{0x810, 0x7F0}, {0x7F0, 0x810}} {
+		s.AddRest(p)
+	}
+	r, e := s.Result()
+	assert.Nil(t, e)
+	assert.Equal(t, Point{0x800, 0x800}, r.Center)
+	assert.Equal(t, Point{0x400, 0x400}, r.Min)
+	assert.Equal(t, Point{0xC00, 0xC00}, r.Max)
+	assert.InDelta(t, 0.0221*restNoiseMargin, r.Deadzone, 0.001) // hypot(16, 16) / 0x400
+
+	// not rotated
+	s = &StickSampler{}
+	s.AddRange(Point{0x800, 0x800})
+	s.AddRest(Point{0x800, 0x800})
+	_, e = s.Result()
+	assert.NotNil(t, e)
+}
+
+func TestStickRangePreferred(t *testing.T) {
+	p := &probe{}
+	jc := newTestJoycon(SideRight, p)
+	jc.handleSubcommandReply(newCalibReply()) // factory: 0x800 +- 0x500
+
+	// the stick only reaches 0x400 from center
+	jc.SetStickRanges([2]*StickRange{nil, {
+		Min:      Point{0x400, 0x400},
+		Center:   Point{0x800, 0x800},
+		Max:      Point{0xC00, 0xC00},
+		Deadzone: 0.1,
+	}})
+	jc.decodeStick(newReport(ButtonState{}, 0xC00, 0x800))
+	jc.decodeStick(newReport(ButtonState{}, 0x850, 0x800)) // inside the deadzone
+	assert.Equal(t, []Ratio{{X: 1, Y: 0}, {X: 0, Y: 0}}, p.sticks)
+
+	// back to the factory one
+	jc.SetStickRanges([2]*StickRange{})
+	jc.decodeStick(newReport(ButtonState{}, 0xC00, 0x800))
+	assert.InDelta(t, 0.8, p.sticks[2].X, 1e-9)
+}
@@ -0,0 +1,94 @@
+package joycon
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStickResponse(t *testing.T) {
+	apply := func(r *StickResponse, x, y float64) Ratio {
+		ratio := Ratio{x, y}
+		r.Apply(&ratio)
+		return ratio
+	}
+	diag := math.Sqrt(0.5) * 0.15 // 0.15 away from center, diagonal
+
+	// radial: a diagonal move outside the deadzone is kept
+	radial := &StickResponse{Shape: Deadzone_Radial, Deadzone: 0.1}
+	assert.Equal(t, NeutralRatio, apply(radial, 0.05, 0.05))
+	assert.InDelta(t, diag, apply(radial, diag, diag).X, 1e-9)
+
+	// axial: each axis is cut separately, it snaps to the axis
+	axial := &StickResponse{Shape: Deadzone_Axial, Deadzone: 0.1}
+	assert.Equal(t, Ratio{0.5, 0}, apply(axial, 0.5, 0.05))
+
+	// scaled radial: starts from 0 at the edge of deadzone
+	scaled := &StickResponse{Shape: Deadzone_ScaledRadial, Deadzone: 0.2}
+	assert.InDelta(t, 0.5, apply(scaled, 0.6, 0).X, 1e-9)
+	assert.InDelta(t, 1, apply(scaled, 1, 0).X, 1e-9)
+
+	// outer deadzone reaches the edge earlier
+	outer := &StickResponse{OuterDeadzone: 0.2}
+	assert.InDelta(t, -1, apply(outer, 0, -0.85).Y, 1e-9)
+	assert.InDelta(t, -0.5, apply(outer, 0, -0.4).Y, 1e-9)
+
+	// anti-deadzone jumps over the game's deadzone
+	anti := &StickResponse{Shape: Deadzone_ScaledRadial, Deadzone: 0.1, AntiDeadzone: 0.2}
+	assert.InDelta(t, 0.2, apply(anti, 0.1000001, 0).X, 1e-5)
+
+	// curves
+	power := &StickResponse{Curve: Curve_Power, Exponent: 2}
+	assert.InDelta(t, 0.25, apply(power, 0.5, 0).X, 1e-9)
+
+	scurve := &StickResponse{Curve: Curve_SCurve, Exponent: 2}
+	assert.InDelta(t, 0.125, apply(scurve, 0.25, 0).X, 1e-9)
+	assert.InDelta(t, 0.875, apply(scurve, 0.75, 0).X, 1e-9)
+
+	points := &StickResponse{Curve: Curve_Points, Points: [][2]float64{{0.5, 0.2}}}
+	assert.InDelta(t, 0.1, apply(points, 0.25, 0).X, 1e-9)
+	assert.InDelta(t, 0.6, apply(points, 0.75, 0).X, 1e-9)
+
+	// zero value changes nothing
+	assert.Equal(t, Ratio{0.3, -0.4}, apply(&StickResponse{}, 0.3, -0.4))
+
+	// invalid settings
+	assert.Nil(t, (&StickResponse{}).Check())
+	assert.NotNil(t, (&StickResponse{Shape: "square"}).Check())
+	assert.NotNil(t, (&StickResponse{Deadzone: 0.6, OuterDeadzone: 0.5}).Check())
+	assert.NotNil(t, (&StickResponse{Curve: Curve_Power}).Check())
+	assert.NotNil(t, (&StickResponse{Curve: Curve_Points, Points: [][2]float64{{0.5, 0.2}, {0.4, 0.3}}}).Check())
+}
+
+func TestDirectionTracker(t *testing.T) {
+	tr := NewDirectionTracker()
+	polar := func(dist, angle float64) *Ratio {
+		rad := angle * math.Pi / 180
+		return &Ratio{dist * math.Cos(rad), dist * math.Sin(rad)}
+	}
+
+	// leaving neutral, not at the edge yet
+	assert.Equal(t, []SpinDirection{Spin_Neutral_Leave}, tr.Update(polar(0.3, 45)))
+	// diagonal is not Up or Right
+	assert.Equal(t, []SpinDirection{Spin_UpRight}, tr.Update(polar(0.9, 45)))
+	assert.Nil(t, tr.Update(polar(0.9, 50)))
+
+	// around the boundary of UpRight/Up(67.5 degrees), it doesn't flicker
+	assert.Nil(t, tr.Update(polar(0.9, 70)))
+	assert.Nil(t, tr.Update(polar(0.9, 66)))
+	assert.Nil(t, tr.Update(polar(0.9, 72)))
+	assert.Equal(t, []SpinDirection{Spin_UpRight_Leave, Spin_Up}, tr.Update(polar(0.9, 75)))
+	assert.Nil(t, tr.Update(polar(0.9, 64)))
+
+	// around the edge threshold
+	assert.Nil(t, tr.Update(polar(0.68, 90)))
+	assert.Equal(t, []SpinDirection{Spin_Up_Leave}, tr.Update(polar(0.6, 90)))
+	assert.Nil(t, tr.Update(polar(0.68, 90)))
+
+	// all the way around
+	assert.Equal(t, []SpinDirection{Spin_DownLeft}, tr.Update(polar(1, -135)))
+	assert.Equal(t, []SpinDirection{Spin_DownLeft_Leave, Spin_Left}, tr.Update(polar(1, 180)))
+	assert.Equal(t, []SpinDirection{Spin_Left_Leave, Spin_UpLeft}, tr.Update(polar(1, 135)))
+	assert.Equal(t, []SpinDirection{Spin_UpLeft_Leave, Spin_Neutral}, tr.Update(&Ratio{}))
+}
@@ -0,0 +1,96 @@
+package joycon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// The reply normally comes in the next report(15ms),
+	// wait much longer for the slow ones like SPI read.
+	subcommandTimeout = 500 * time.Millisecond
+	subcommandRetries = 3
+)
+
+var (
+	ErrNoReply  = errors.New("no reply")
+	ErrRejected = errors.New("rejected by the controller") // NACK
+)
+
+// an outstanding subcommand, waiting for its reply
+type pendingReply struct {
+	id     byte   // subcommand ID
+	prefix []byte // the reply data must start with this, e.g. the address of SPI read
+	ch     chan subcommandReply
+}
+
+type subcommandReply struct {
+	ack  byte
+	data []byte
+}
+
+// Send a subcommand and wait for its 0x21 reply, resend it if no reply in time.
+// Returns the reply data, which starts from byte 15 of the report.
+func (jc *joycon) request(
+	sub []byte,
+	prefix []byte,
+	timeout time.Duration,
+	retries int,
+) ([]byte, error) {
+	// one at a time, so a reply always matches the only outstanding request
+	jc.muRequest.Lock()
+	defer jc.muRequest.Unlock()
+
+	p := &pendingReply{
+		id:     sub[0],
+		prefix: prefix,
+		ch:     make(chan subcommandReply, 1),
+	}
+	jc.setPending(p)
+	defer jc.setPending(nil)
+
+	for try := 0; try <= retries; try++ {
+		if e := jc.sendSubcommand(sub, nil); e != nil {
+			return nil, e
+		}
+
+		select {
+		case reply := <-p.ch:
+			if reply.ack&0x80 == 0 {
+				return nil, fmt.Errorf("subcommand %02X: %w", sub[0], ErrRejected)
+			}
+			return reply.data, nil
+		case <-time.After(timeout):
+		}
+	}
+	return nil, fmt.Errorf("subcommand %02X: %w after %d tries", sub[0], ErrNoReply, retries+1)
+}
+
+// with the default timeout and retries
+func (jc *joycon) subcommand(sub []byte) ([]byte, error) {
+	return jc.request(sub, nil, subcommandTimeout, subcommandRetries)
+}
+
+func (jc *joycon) setPending(p *pendingReply) {
+	jc.muPending.Lock()
+	defer jc.muPending.Unlock()
+
+	jc.pending = p
+}
+
+// pass the reply to the waiting request, returns false if nobody is waiting for it
+func (jc *joycon) deliverReply(id, ack byte, data []byte) bool {
+	jc.muPending.Lock()
+	defer jc.muPending.Unlock()
+
+	p := jc.pending
+	if p == nil || p.id != id || !bytes.HasPrefix(data, p.prefix) {
+		return false
+	}
+	jc.pending = nil // a late duplicate won't be delivered twice
+
+	p.ch <- subcommandReply{ack, append([]byte{}, data...)} // the buffer is reused by readLoop
+	return true
+}
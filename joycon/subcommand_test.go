@@ -0,0 +1,125 @@
+package joycon
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// answers each output report with `respond`, nil for no reply
+type replyTransport struct {
+	mu      sync.Mutex
+	writes  int
+	respond func(sub []byte) []byte
+	reports chan []byte
+}
+
+func newReplyTransport(respond func(sub []byte) []byte) *replyTransport {
+	return &replyTransport{respond: respond, reports: make(chan []byte, 16)}
+}
+
+func (r *replyTransport) Read(report []byte) (int, error) {
+	packet, ok := <-r.reports
+	if !ok {
+		return 0, io.EOF
+	}
+	return copy(report, packet), nil
+}
+func (r *replyTransport) Write(report []byte) (int, error) {
+	r.mu.Lock()
+	r.writes++
+	r.mu.Unlock()
+
+	if reply := r.respond(report[10:]); reply != nil {
+		r.reports <- reply
+	}
+	return len(report), nil
+}
+func (r *replyTransport) Close() error {
+	close(r.reports)
+	return nil
+}
+
+func newSubcommandReply(ack, id byte, data []byte) []byte {
+	packet := make([]byte, 49)
+	packet[0] = 0x21
+	packet[13] = ack
+	packet[14] = id
+	copy(packet[15:], data)
+	return packet
+}
+
+func TestSubcommand(t *testing.T) {
+	var dropped bool
+
+	tr := newReplyTransport(func(sub []byte) []byte {
+		switch sub[0] {
+		case 0x10: // SPI read, echo the address, data: 1, 2, 3...
+			data := append([]byte{}, sub[1:6]...)
+			for i := byte(0); i < sub[5]; i++ {
+				data = append(data, i+1)
+			}
+			return newSubcommandReply(0x90, 0x10, data)
+		case 0x30: // lights, the first one is lost
+			if !dropped {
+				dropped = true
+				return nil
+			}
+			return newSubcommandReply(0x80, 0x30, nil)
+		case 0x40: // gyro, rejected
+			return newSubcommandReply(0x00, 0x40, nil)
+		}
+		return nil // no reply
+	})
+	jc := newTestJoycon(SideRight, &probe{})
+	jc.transport = tr
+	go jc.readLoop()
+
+	// reply data
+	data, e := jc.SPIRead(0x6000, 4)
+	assert.Nil(t, e)
+	assert.Equal(t, []byte{1, 2, 3, 4}, data)
+
+	// a reply of another address doesn't match
+	tr.reports <- newSubcommandReply(0x90, 0x10, []byte{0, 0x70, 0, 0, 4})
+	data, e = jc.SPIRead(0x6000, 4)
+	assert.Nil(t, e)
+	assert.Equal(t, []byte{1, 2, 3, 4}, data)
+
+	// retried
+	tr.writes = 0
+	_, e = jc.request([]byte{0x30, 1}, nil, 50*time.Millisecond, 2)
+	assert.Nil(t, e)
+	assert.Equal(t, 2, tr.writes)
+
+	// NACK
+	assert.True(t, errors.Is(jc.EnableGyro(true), ErrRejected))
+
+	// timeout
+	tr.writes = 0
+	_, e = jc.request([]byte{0x06}, nil, 20*time.Millisecond, 2)
+	assert.True(t, errors.Is(e, ErrNoReply))
+	assert.Equal(t, 3, tr.writes)
+
+	calib := newCalibReply()
+	tr.respond = func(sub []byte) []byte {
+		if binary.LittleEndian.Uint32(sub[1:]) == factoryStickCalibStart {
+			return calib
+		}
+		return newSubcommandReply(0x90, 0x10, sub[1:]) // no user calibration
+	}
+
+	// reading it for information doesn't apply it
+	_, e = jc.SPIRead(factoryStickCalibStart, factoryStickCalibLen)
+	assert.Nil(t, e)
+	assert.False(t, jc.isCalibrated())
+
+	// the SPI reply of calibration is also handled as calibration
+	assert.Nil(t, jc.CalibrateStick())
+	assert.True(t, jc.isCalibrated())
+}
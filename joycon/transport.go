@@ -0,0 +1,29 @@
+package joycon
+
+import (
+	"github.com/sstallion/go-hid"
+)
+
+// Transport is the channel that carries raw HID reports to and from a controller.
+// The hidapi device is the default one, others can be used for recording/replaying.
+type Transport interface {
+	// Blocking read of one input report, returns the report size
+	Read(report []byte) (int, error)
+	// Send one output report
+	Write(report []byte) (int, error)
+
+	Close() error
+}
+
+// Transport backed by hidapi
+type hidTransport struct {
+	dev *hid.Device
+}
+
+func NewHidTransport(dev *hid.Device) Transport {
+	return &hidTransport{dev: dev}
+}
+
+func (t *hidTransport) Read(report []byte) (int, error)  { return t.dev.Read(report) }
+func (t *hidTransport) Write(report []byte) (int, error) { return t.dev.Write(report) }
+func (t *hidTransport) Close() error                     { return t.dev.Close() }
@@ -0,0 +1,101 @@
+package joycon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sstallion/go-hid"
+)
+
+// Over USB, the controller doesn't push HID reports until a handshake is made.
+// ref: https://github.com/dekuNukem/Nintendo_Switch_Reverse_Engineering/blob/master/USB-HID-Notes.md
+const (
+	usbOutputReport byte = 0x80
+	usbInputReport  byte = 0x81
+
+	usbCmdStatus    byte = 0x01 // returns controller type and MAC
+	usbCmdHandshake byte = 0x02
+	usbCmdBaudrate  byte = 0x03 // switch to 3Mbit
+	usbCmdHidOnly   byte = 0x04 // talk HID only, no more USB timeout
+
+	usbReplyTimeout = 500 * time.Millisecond
+)
+
+// send USB command and wait for its reply, it returns the reply packet
+func usbCommand(dev *hid.Device, cmd byte, waitReply bool) ([]byte, error) {
+	if _, e := dev.Write([]byte{usbOutputReport, cmd}); e != nil {
+		return nil, e
+	}
+	if !waitReply {
+		return nil, nil
+	}
+
+	var buffer [0x200]byte
+	deadline := time.Now().Add(usbReplyTimeout)
+
+	// there may be other reports before the reply, skip them
+	for time.Now().Before(deadline) {
+		n, e := dev.ReadWithTimeout(buffer[:], time.Until(deadline))
+		if e != nil {
+			if errors.Is(e, hid.ErrTimeout) {
+				break
+			}
+			return nil, e
+		}
+		if n >= 2 && buffer[0] == usbInputReport && buffer[1] == cmd {
+			return append([]byte{}, buffer[:n]...), nil
+		}
+	}
+	return nil, fmt.Errorf("usb command %02x: no reply", cmd)
+}
+
+// UsbHandshake switches a USB connected controller to HID report mode,
+// after that it works the same as over Bluetooth.
+// It returns the side and MAC reported by the controller.
+func UsbHandshake(dev *hid.Device) (JoyConSide, string, error) {
+	status, e := usbCommand(dev, usbCmdStatus, true)
+	if e != nil {
+		return SideInvalid, "", e
+	}
+	// 81 01 00 <type> <mac, 6 bytes, reversed>
+	if len(status) < 10 || status[2] != 0 {
+		// the charging grip replies with error if the slot is empty
+		return SideInvalid, "", errors.New("no controller attached")
+	}
+
+	var side JoyConSide
+	switch status[3] {
+	case 1:
+		side = SideLeft
+	case 2:
+		side = SideRight
+	case 3:
+		side = SideBoth
+	default:
+		return SideInvalid, "", fmt.Errorf("unknown controller type: %d", status[3])
+	}
+
+	mac := make(net.HardwareAddr, 6)
+	for i := 0; i < 6; i++ {
+		mac[i] = status[9-i]
+	}
+
+	if _, e := usbCommand(dev, usbCmdHandshake, true); e != nil {
+		return SideInvalid, "", e
+	}
+	if _, e := usbCommand(dev, usbCmdBaudrate, true); e != nil {
+		return SideInvalid, "", e
+	}
+	// handshake again with the new baudrate
+	if _, e := usbCommand(dev, usbCmdHandshake, true); e != nil {
+		return SideInvalid, "", e
+	}
+	// no reply for this one
+	if _, e := usbCommand(dev, usbCmdHidOnly, false); e != nil {
+		return SideInvalid, "", e
+	}
+
+	return side, mac.String(), nil
+}
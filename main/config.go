@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"time"
 
 	"github.com/aj3423/joy-typing/joycon"
 	"github.com/aj3423/joy-typing/mode"
@@ -20,11 +21,31 @@ type Config struct {
 	LogLevel             log.Level `comment:"panic,fatal,error,warn,info,debug,trace"`
 	SpinNeutralThreshold float64   `comment:"Stick is considered as 'neutral' if the spinning ratio is below this percentage (range: 0~1.0)"`
 	SpinEdgeThreshold    float64   `comment:"Stick Up/Down/Left/Right events are triggered when the spinning ratio exceeds this value (range: 0~1.0)"`
+	PairJoycons          bool      `comment:"Combine the left and right Joy-Con into one controller when both are connected, so a rule can use buttons of both sides like 'ZL + ZR'"`
+	StaleTimeout         int       `comment:"A controller is reconnected if no report is received for this long (in milliseconds), 0 to disable"`
+	EvdevBackend         bool      `comment:"Linux only, read the controllers through the hid-nintendo kernel driver instead of hidraw, rumble and lights are not supported"`
+
+	StickResponse      joycon.StickResponse  `comment:"Deadzone and response curve of both sticks, applied before any stick rule"`
+	LeftStickResponse  *joycon.StickResponse `comment:"Overrides 'StickResponse' for the left stick, optional"`
+	RightStickResponse *joycon.StickResponse `comment:"Overrides 'StickResponse' for the right stick, optional"`
 	// use these 3 simple structs instead of embed other struct,
 	// because that would result in a complex layout in config file.
 	ModeList    []mode.ModeConfig   `toml:"Mode,multiline" comment:"rules for all modes"`
 	PhraseList  map[string][]string `toml:"PhraseList,multiline" comment:"This section is used to narrow down the word dictionary of a speech mode,\n used as parameter '-phrase' of 'speech mode', can appear multiple times,\n for example: '[speech] -id MyGolangMode -phrase common application java lua'"`
 	WordMapping map[string][]string `toml:"WordMapping,multiline" comment:"Can't figure out how to display the items below in multiline, just format it with some online formatter and copy back:-)"`
+
+	Aliases  map[string]string            `toml:"Alias" comment:"Names of controllers by MAC, e.g. \"70:48:F7:76:BC:87\" = \"alice\",\n each name has its own mode session, controllers with the same name share one"`
+	Profiles map[string][]mode.ModeConfig `toml:"Profile,multiline" comment:"Mode rules of a name in 'Alias', used instead of the 'Mode' rules above,\n e.g. '[[Profile.alice]]' has the same layout as '[[Mode]]'"`
+
+	KeyDevices map[string]KeyDevice `toml:"KeyDevice" comment:"Linux only, other input devices like foot pedals or macro pads, by a name used in rules,\n e.g. '[KeyDevice.pedal]' for the rule '[switch] key -device pedal -code BTN_0 -> [mode] -id WordMode'"`
+}
+
+// An evdev device whose keys are used in rules
+type KeyDevice struct {
+	Path  string `comment:"The event node, better a stable link like '/dev/input/by-id/usb-...-event-kbd'"`
+	Name  string `comment:"Or the device name reported by the kernel, used if 'Path' is empty"`
+	Grab  bool   `comment:"Take the device exclusively, its keys don't reach other programs"`
+	Alias string `comment:"Only the controllers with this name in 'Alias' receive the keys, all controllers if empty"`
 }
 
 func loadConfig() (e error) {
@@ -46,6 +67,10 @@ func loadConfig() (e error) {
 	if e = toml.Unmarshal(s, cfg); e != nil {
 		return e
 	}
+	responses, e := cfg.stickResponses()
+	if e != nil {
+		return e
+	}
 	// save to cfg, it's used when `saveConfig()`
 	currCfg = cfg
 
@@ -55,18 +80,34 @@ func loadConfig() (e error) {
 	mode.WordMapping = currCfg.WordMapping
 	joycon.SpinNeutralThreshold = currCfg.SpinNeutralThreshold
 	joycon.SpinEdgeThreshhold = currCfg.SpinEdgeThreshold
+	joycon.StickResponses = responses
+	joycon.StaleTimeout = time.Duration(currCfg.StaleTimeout) * time.Millisecond
 	log.SetLevel(currCfg.LogLevel)
 
-	modes, modeSitches, e := mode.Parse()
-	if e != nil {
+	if _, _, e = mode.Parse(); e != nil {
 		return fmt.Errorf("failed to parse mode: %s", e.Error())
 	}
-	if e := mode.Manager.SetModes(modes, modeSitches); e != nil {
+	// each controller gets a new session with the new rules
+	if e = sessions.reset(currCfg); e != nil {
 		return fmt.Errorf("failed to parse mode: %s", e.Error())
 	}
 	return nil
 }
 
+// the global one, or the per stick one if it's set
+func (cfg *Config) stickResponses() (ret [2]*joycon.StickResponse, e error) {
+	for i, r := range []*joycon.StickResponse{cfg.LeftStickResponse, cfg.RightStickResponse} {
+		if r == nil {
+			r = &cfg.StickResponse
+		}
+		if e = r.Check(); e != nil {
+			return ret, fmt.Errorf("invalid stick response: %s", e.Error())
+		}
+		ret[i] = r
+	}
+	return
+}
+
 // reload config when the file is modified
 func watchConfig() chan struct{} {
 	stop := make(chan struct{})
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/aj3423/joy-typing/joycon"
 	"github.com/aj3423/joy-typing/mode"
 	log "github.com/sirupsen/logrus"
@@ -11,6 +13,11 @@ var currCfg = &Config{
 	LogLevel:             log.InfoLevel,
 	SpinNeutralThreshold: joycon.SpinNeutralThreshold,
 	SpinEdgeThreshold:    joycon.SpinEdgeThreshhold,
+	StaleTimeout:         int(joycon.StaleTimeout / time.Millisecond),
+	StickResponse: joycon.StickResponse{
+		Shape: joycon.Deadzone_ScaledRadial,
+		Curve: joycon.Curve_Linear,
+	},
 	ModeList: []mode.ModeConfig{
 		{
 			Mode: `[idle] -id id1`,
@@ -41,7 +48,7 @@ var currCfg = &Config{
 
 				// to verfy if the gyro has been turned off after leaving mouse mode,
 				// if the packet lost and gyro fails to stop, it can trigger mouse move in this mode
-				`[trigger] gyro -> [cursor] -speed 0.03`,
+				`[trigger] gyro -> [cursor] -speed 30`,
 			},
 		},
 		{
@@ -82,7 +89,7 @@ var currCfg = &Config{
 		{
 			Mode: `[gyro] -id MouseMode`,
 			Rules: []string{
-				`[trigger] gyro -> [cursor] -speed 0.03`,
+				`[trigger] gyro -> [cursor] -speed 30`,
 				`[switch]  button -id R   -> [mouse_toggle]`,
 				`[trigger] button -id ZR  -> [click]`,
 				`[trigger] button -id X   -> [click] -button right`,
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aj3423/joy-typing/joycon"
+	"github.com/fatih/color"
+	log "github.com/sirupsen/logrus"
+)
+
+// print the device info and the known SPI regions
+func printDeviceInfo(dev joycon.Device) {
+	fmt.Printf("  <%s> %s\n", dev.Side().String(), dev.Mac())
+
+	di, e := dev.DeviceInfo()
+	if e != nil {
+		color.HiRed("    fail to get device info: %s", e.Error())
+		return
+	}
+	fmt.Printf("    firmware: %s, type: %s, MAC: %s\n", di.Firmware(), di.Type.String(), di.Mac)
+
+	fi, e := joycon.ReadFlashInfo(dev.SPIRead)
+	if e != nil {
+		color.HiRed("    fail to read SPI: %s", e.Error())
+		return
+	}
+	fmt.Printf("    serial: %s\n", fi.Serial)
+	fmt.Printf("    colors: body %s, buttons %s", fi.Body, fi.Buttons)
+	if dev.Side() == joycon.SideBoth {
+		fmt.Printf(", grips %s %s", fi.LeftGrip, fi.RightGrip)
+	}
+	fmt.Println()
+
+	// 0: left stick, 1: right stick
+	for i, has := range []bool{dev.Side().IsLeft(), dev.Side().IsRight()} {
+		if !has {
+			continue
+		}
+		fmt.Printf("    stick %d factory: %s\n", i, fi.FactoryStick[i])
+		if fi.UserStick[i] != nil {
+			fmt.Printf("    stick %d user:    %s\n", i, fi.UserStick[i])
+		}
+	}
+	fmt.Printf("    IMU factory: %s\n", fi.FactoryImu)
+	if fi.UserImu != nil {
+		fmt.Printf("    IMU user:    %s\n", fi.UserImu)
+	}
+}
+
+// dump the whole SPI flash to `dir`, it takes minutes
+func dumpFlash(dev joycon.Device, dir string) {
+	fn := fmt.Sprintf("%s_%s.bin",
+		strings.ReplaceAll(dev.Mac(), ":", ""), time.Now().Format("20060102_150405"))
+	fn = filepath.Join(dir, fn)
+
+	f, e := os.Create(fn)
+	if e != nil {
+		log.Errorf("fail to create dump file: %s", e.Error())
+		return
+	}
+	defer f.Close()
+
+	log.Infof("Dumping SPI flash of <%s> to: %s", dev.Side(), fn)
+
+	percent := -1
+	e = joycon.DumpSPI(dev.SPIRead, f, func(done, total int) {
+		if p := done * 100 / total; p/10 != percent/10 {
+			percent = p
+			log.Infof("%s: dumped %d%%", dev.Mac(), p)
+		}
+	})
+	if e != nil {
+		log.Errorf("fail to dump SPI flash of %s: %s", dev.Mac(), e.Error())
+	}
+}
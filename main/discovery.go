@@ -0,0 +1,322 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aj3423/joy-typing/joycon"
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	"github.com/sstallion/go-hid"
+)
+
+// What happened to a hid device during discovery
+const (
+	discover_Opened  = "opened"
+	discover_Skipped = "skipped"
+	discover_Failed  = "failed"
+)
+
+type discovered struct {
+	path    string
+	product uint16
+	serial  string
+	result  string
+	reason  string
+}
+
+func (d *discovered) String() string {
+	s := fmt.Sprintf("%04x %s %s: %s", d.product, d.serial, d.path, d.result)
+	if d.reason != "" {
+		s += ", " + d.reason
+	}
+	return s
+}
+
+// Check all Nintendo hid devices, open the new ones.
+// Any number of controllers are supported, they're identified by the MAC(serial number).
+func (m *Manager) CheckNewDevice() []*discovered {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	all := []*discovered{}
+	present := map[string]bool{}
+	seen := map[string]bool{} // MACs in this round, a device may have several interfaces
+
+	hid.Enumerate(joycon.VENDOR_NINTENDO, hid.ProductIDAny,
+		func(info *hid.DeviceInfo) error {
+			present[info.Path] = true
+			d := &discovered{path: info.Path, product: info.ProductID, serial: info.SerialNbr}
+			all = append(all, d)
+
+			if _, exist := m.paths[info.Path]; exist {
+				d.result, d.reason = discover_Skipped, "already connected"
+				return nil
+			}
+
+			switch info.ProductID {
+			case joycon.JOYCON_PRODUCT_L, joycon.JOYCON_PRODUCT_R, joycon.JOYCON_PRODUCT_PRO:
+				mac := info.SerialNbr
+				switch {
+				case currCfg.EvdevBackend:
+					d.result, d.reason = discover_Skipped, "using the evdev backend"
+				case mac == "":
+					d.result, d.reason = discover_Failed, "no serial number"
+				case seen[mac] || m.findByMac(mac) != nil:
+					d.result, d.reason = discover_Skipped, "already connected"
+				default:
+					seen[mac] = true
+					m.openDevice(d, joycon.ProductSide[info.ProductID], mac)
+				}
+			case joycon.JOYCON_PRODUCT_CHARGEGRIP:
+				m.openGripSlot(d)
+			default:
+				d.result, d.reason = discover_Skipped, "unsupported product"
+			}
+			return nil
+		})
+
+	if currCfg.EvdevBackend {
+		all = append(all, m.checkEvdev(present, seen)...)
+	}
+	all = append(all, m.checkKeyDevices()...)
+
+	// the removed ones
+	for path := range m.emptySlots {
+		if !present[path] {
+			delete(m.emptySlots, path)
+		}
+	}
+	for path, jc := range m.paths {
+		if present[path] {
+			continue
+		}
+		delete(m.paths, path)
+		owner := m.ownerOf(jc)
+		if p, ok := owner.(*joycon.Paired); ok { // the other half stays connected
+			m.unpair(p)
+			owner = jc
+		}
+		if owner != nil {
+			m.remove(owner, false)
+		}
+	}
+
+	m.report(all)
+	return all
+}
+
+func (m *Manager) openDevice(d *discovered, side joycon.JoyConSide, mac string) {
+	dev, e := hid.OpenPath(d.path)
+	if e != nil {
+		d.result, d.reason = discover_Failed, e.Error()
+		return
+	}
+
+	transport := joycon.NewHidTransport(dev)
+	if m.recordDir != "" {
+		transport = m.record(transport, side, mac)
+	}
+
+	jc := m.newController(transport, side, mac)
+	m.paths[d.path] = jc
+	m.addNewDevice(jc)
+	d.result = discover_Opened
+}
+
+// Each handshake of an empty slot waits for the timeout,
+// don't repeat it on every check.
+const gripRecheck = 10 * time.Second
+
+type emptySlot struct {
+	until  time.Time // checked again after it
+	reason string
+}
+
+// The charging grip has one hid interface for each slot,
+// the Joy-Con in a slot works over USB after the handshake.
+func (m *Manager) openGripSlot(d *discovered) {
+	if slot, ok := m.emptySlots[d.path]; ok && time.Now().Before(slot.until) {
+		d.result, d.reason = discover_Skipped, slot.reason
+		return
+	}
+	delete(m.emptySlots, d.path)
+
+	dev, e := hid.OpenPath(d.path)
+	if e != nil {
+		d.result, d.reason = discover_Failed, e.Error()
+		return
+	}
+
+	side, mac, e := joycon.UsbHandshake(dev)
+	if e != nil {
+		dev.Close()
+		d.result, d.reason = discover_Skipped, "empty grip slot: "+e.Error()
+		m.emptySlots[d.path] = emptySlot{until: time.Now().Add(gripRecheck), reason: d.reason}
+		return
+	}
+	d.serial = mac
+
+	transport := joycon.NewHidTransport(dev)
+	if m.recordDir != "" {
+		transport = m.record(transport, side, mac)
+	}
+
+	jc := m.newController(transport, side, mac)
+	m.paths[d.path] = jc
+	m.addNewDevice(jc)
+	d.result = discover_Opened
+}
+
+// The controllers handled by the hid-nintendo kernel driver, through their evdev nodes
+func (m *Manager) checkEvdev(present, seen map[string]bool) []*discovered {
+	nodes, e := joycon.FindEvdevNodes()
+	if e != nil {
+		log.Debugf("evdev: %s", e.Error())
+		return nil
+	}
+
+	all := []*discovered{}
+	for _, n := range nodes {
+		present[n.Input] = true
+		d := &discovered{path: n.Input, product: productOf(n.Side), serial: n.Mac}
+		all = append(all, d)
+
+		if _, exist := m.paths[n.Input]; exist || seen[n.Mac] || m.findByMac(n.Mac) != nil {
+			d.result, d.reason = discover_Skipped, "already connected"
+			continue
+		}
+		seen[n.Mac] = true
+
+		jc, e := joycon.NewEvdevJoycon(n)
+		if e != nil {
+			d.result, d.reason = discover_Failed, e.Error()
+			continue
+		}
+		if n.Imu == "" {
+			d.reason = "no IMU node"
+		}
+		m.paths[n.Input] = jc
+		m.addNewDevice(jc)
+		d.result = discover_Opened
+	}
+	return all
+}
+
+func productOf(side joycon.JoyConSide) uint16 {
+	for product, s := range joycon.ProductSide {
+		if s == side {
+			return product
+		}
+	}
+	return 0
+}
+
+// log the devices whose result changed, so polling doesn't flood the log
+func (m *Manager) report(all []*discovered) {
+	last := m.reported
+	m.reported = map[string]string{}
+
+	for _, d := range all {
+		s := d.String()
+		m.reported[d.path] = s
+		if last[d.path] == s {
+			continue
+		}
+		switch d.result {
+		case discover_Opened:
+			log.Infof("Device %s", s)
+		case discover_Failed:
+			log.Warningf("Device %s", s)
+		default:
+			log.Debugf("Device %s", s)
+		}
+	}
+}
+
+// the connected one, or the half of a paired one
+func (m *Manager) findByMac(mac string) joycon.Controller {
+	for jc := range m.connected {
+		for _, half := range halvesOf(jc) {
+			if normalizeMac(half.Mac()) == normalizeMac(mac) {
+				return half
+			}
+		}
+	}
+	return nil
+}
+
+// the connected controller that `jc` belongs to, itself or the paired one
+func (m *Manager) ownerOf(jc joycon.Controller) joycon.Controller {
+	for c := range m.connected {
+		for _, half := range halvesOf(c) {
+			if half == jc {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// wait for udev to set the permission of the new node
+const nodeSettle = 500 * time.Millisecond
+
+// device nodes to watch, directory => name prefix
+var watchedNodes = map[string]string{
+	"/dev":       "hidraw", // each hid device has a /dev/hidrawN
+	"/dev/input": "event",  // evdev nodes of the hid-nintendo driver and key devices
+}
+
+// The device nodes are created and removed by udev on Linux,
+// `onChange` is called when any of them is added or removed.
+func watchDevNodes(onChange func()) error {
+	if runtime.GOOS != "linux" {
+		return errors.New("only supported on Linux")
+	}
+	w, e := fsnotify.NewWatcher()
+	if e != nil {
+		return e
+	}
+	for dir := range watchedNodes {
+		if e = w.Add(dir); e != nil {
+			w.Close()
+			return e
+		}
+	}
+
+	go func() {
+		defer w.Close()
+
+		var settle *time.Timer
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				dir, name := filepath.Split(ev.Name)
+				prefix, ok := watchedNodes[filepath.Clean(dir)]
+				if !ok || !strings.HasPrefix(name, prefix) ||
+					!ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Remove) {
+					continue
+				}
+				log.Debugf("device node: %s", ev)
+				// several events come together, check once after they settle
+				if settle != nil {
+					settle.Stop()
+				}
+				settle = time.AfterFunc(nodeSettle, onChange)
+			case e, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Warningf("device node watcher: %s", e.Error())
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/aj3423/joy-typing/evdev"
+	"github.com/aj3423/joy-typing/joycon"
+	"github.com/aj3423/joy-typing/mode"
+	log "github.com/sirupsen/logrus"
+)
+
+// An opened device of the 'KeyDevice' section, like a foot pedal
+type keyDevice struct {
+	name string // the name in config, used by `-device` in rules
+	cfg  KeyDevice
+	dev  *evdev.Device
+}
+
+// Open the configured key devices that aren't opened yet,
+// the ones removed from config are closed.
+func (m *Manager) checkKeyDevices() []*discovered {
+	for name, kd := range m.keyDevices {
+		if cfg, ok := currCfg.KeyDevices[name]; !ok || cfg != kd.cfg {
+			m.closeKeyDevice(kd)
+		}
+	}
+	if len(currCfg.KeyDevices) == 0 {
+		return nil
+	}
+
+	nodes, e := evdev.List()
+	if e != nil {
+		log.Debugf("key device: %s", e.Error())
+		return nil
+	}
+
+	all := []*discovered{}
+	for name, cfg := range currCfg.KeyDevices {
+		if kd, ok := m.keyDevices[name]; ok {
+			all = append(all, &discovered{path: kd.dev.Path(), serial: name,
+				result: discover_Skipped, reason: "already opened"})
+			continue
+		}
+		path := findKeyDevice(&cfg, nodes)
+		if path == "" {
+			continue
+		}
+		d := &discovered{path: path, serial: name}
+		all = append(all, d)
+		m.openKeyDevice(d, name, cfg)
+	}
+	return all
+}
+
+// the event node matching `Path` or `Name`, "" if it's not plugged in
+func findKeyDevice(cfg *KeyDevice, nodes []string) string {
+	if cfg.Path != "" {
+		path, e := filepath.EvalSymlinks(cfg.Path)
+		if e != nil {
+			return ""
+		}
+		return path
+	}
+	for _, node := range nodes {
+		if name, e := evdev.NameOf(node); e == nil && name == cfg.Name {
+			return node
+		}
+	}
+	return ""
+}
+
+func (m *Manager) openKeyDevice(d *discovered, name string, cfg KeyDevice) {
+	dev, e := evdev.Open(d.path)
+	if e != nil {
+		d.result, d.reason = discover_Failed, e.Error()
+		return
+	}
+	if cfg.Grab {
+		if e = dev.Grab(true); e != nil {
+			dev.Close()
+			d.result, d.reason = discover_Failed, "grab: "+e.Error()
+			return
+		}
+	}
+	kd := &keyDevice{name: name, cfg: cfg, dev: dev}
+	m.keyDevices[name] = kd
+	d.reason = dev.Name()
+	d.result = discover_Opened
+
+	go m.readKeys(kd)
+}
+
+// closing it interrupts `readKeys()`
+func (m *Manager) closeKeyDevice(kd *keyDevice) {
+	delete(m.keyDevices, kd.name)
+	kd.dev.Close()
+}
+
+func (m *Manager) readKeys(kd *keyDevice) {
+	for {
+		events, e := kd.dev.Read()
+		if e != nil {
+			m.mu.Lock()
+			if m.keyDevices[kd.name] == kd { // unplugged, not closed by us
+				log.Warningf("Key device %s removed: %s", kd.name, e.Error())
+				m.closeKeyDevice(kd)
+			}
+			m.mu.Unlock()
+			return
+		}
+		for _, ev := range events {
+			if ev.Type != evdev.EV_KEY || ev.Value > 1 { // 2 for auto repeat
+				continue
+			}
+			m.onKey(kd, ev.Code, ev.Value == 1)
+		}
+	}
+}
+
+// Pass the key to the sessions of the connected controllers,
+// a session receives it once even if several controllers share it,
+// with the one chosen by `preferred()`, e.g. for the gyro or rumble.
+// Without any, it goes to the session of the alias or the default one, without controller.
+func (m *Manager) onKey(kd *keyDevice, code uint16, pressed bool) {
+	log.Tracef("onKey, %s: %s %v", kd.name, evdev.KeyName(code), pressed)
+
+	targets := map[*mode.ModeManager]joycon.Controller{}
+	m.mu.Lock()
+	for jc := range m.connected {
+		if kd.cfg.Alias != "" && sessions.aliasOf(jc) != kd.cfg.Alias {
+			continue
+		}
+		mm, e := sessions.of(jc)
+		if e != nil {
+			continue
+		}
+		if curr, ok := targets[mm]; !ok || preferred(jc, curr) {
+			targets[mm] = jc
+		}
+	}
+	m.mu.Unlock()
+
+	if len(targets) == 0 {
+		mm, e := sessions.ofKeyDevice(kd.cfg.Alias)
+		if e != nil {
+			log.Errorf("key device %s: %s", kd.name, e.Error())
+			return
+		}
+		targets[mm] = nil
+	}
+	for mm, jc := range targets {
+		mm.Handle(&mode.Input{
+			Type: mode.InputType_Key,
+
+			Jc: jc,
+			KeyInput: &mode.KeyInput{
+				Device: kd.name, Code: code, Pressed: pressed,
+			},
+		})
+	}
+}
+
+// The controller sent with the key when several ones share a session,
+// the same one every time: a paired one, then the lowest MAC.
+func preferred(jc, than joycon.Controller) bool {
+	_, jcPaired := jc.(*joycon.Paired)
+	_, thanPaired := than.(*joycon.Paired)
+	if jcPaired != thanPaired {
+		return jcPaired
+	}
+	return jc.Mac() < than.Mac()
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/aj3423/joy-typing/joycon"
 	"github.com/c-bata/go-prompt"
 	"github.com/fatih/color"
 	"github.com/mattn/go-colorable"
@@ -48,9 +49,25 @@ func executor(in string) {
 
 		for jc := range mgr.connected {
 			// joycon.SetPlayerLights(jc, byte((n)<<4)) // flashing
-			jc.SetLights(byte((n))) // stay on, 1 frame anyway
+			jc.SetLights(byte((n))) // stay on, it's re-sent periodically
 		}
 
+		return
+	case "home": // set the HOME light
+		if argc != 2 {
+			color.HiRed("usage: home <pattern>\n e.g. home pulse")
+			return
+		}
+		h, ok := joycon.HomeLightPatterns[arg[1]]
+		if !ok {
+			color.HiRed("unknown pattern: %s", arg[1])
+			return
+		}
+		for jc := range mgr.connected {
+			if e := jc.SetHomeLight(h); e != nil {
+				color.HiRed("fail to set HOME light: %s", e.Error())
+			}
+		}
 		return
 	case "connect": // connect to my right joycon
 		var adapter = bluetooth.DefaultAdapter
@@ -67,12 +84,64 @@ func executor(in string) {
 
 		return
 	case "list": // list all connected devices
+		// the device info requests are slow, don't block the manager
+		fmt.Println("Connected JoyCons:")
+		for _, jc := range mgr.controllers() {
+			fmt.Printf("<%s> %s %s\n", jc.Side().String(), jc.Mac(), renderBattery(jc.Battery()))
+
+			if mm, e := sessions.of(jc); e == nil {
+				fmt.Printf("  session: %s, mode: %s\n", mm.Name(), mm.CurrentMode().Id())
+			}
+
+			drift := jc.StickDrift()
+			fmt.Printf("  stick drift: left %s, right %s\n", &drift[0], &drift[1])
+
+			for _, half := range halvesOf(jc) {
+				if dev, ok := half.(joycon.Device); ok {
+					printDeviceInfo(dev)
+				}
+			}
+		}
+		return
+	case "scan": // check the hid devices now, report what's done to each
+		all := mgr.CheckNewDevice()
+		if len(all) == 0 {
+			fmt.Println("No Nintendo hid device found")
+		}
+		for _, d := range all {
+			fmt.Println(d.String())
+		}
+		return
+	case "stats": // report statistics, "stats reset" restarts counting
 		mgr.mu.Lock()
 		defer mgr.mu.Unlock()
 
-		fmt.Println("Connected JoyCons:")
 		for jc := range mgr.connected {
-			fmt.Printf("<%s> %s %s\n", jc.Side().String(), jc.Mac(), renderBattery(jc.Battery()))
+			for _, half := range halvesOf(jc) {
+				if argc > 1 && arg[1] == "reset" {
+					half.ResetStats()
+					continue
+				}
+				s := half.Stats()
+				fmt.Printf("<%s> %s, since %s\n  %s\n",
+					half.Side().String(), half.Mac(), s.Since.Format("15:04:05"), s.String())
+			}
+		}
+		return
+	case "dump": // dump SPI flash of all connected controllers
+		if argc != 2 {
+			color.HiRed("usage: dump <dir>\n e.g. dump /tmp")
+			return
+		}
+		mgr.mu.Lock()
+		defer mgr.mu.Unlock()
+
+		for jc := range mgr.connected {
+			for _, half := range halvesOf(jc) {
+				if dev, ok := half.(joycon.Device); ok {
+					go dumpFlash(dev, arg[1])
+				}
+			}
 		}
 		return
 	case "calib": // calibrate stick
@@ -80,6 +149,47 @@ func executor(in string) {
 			jc.CalibrateStick()
 		}
 		return
+	case "stickcalib": // measure the stick range, for worn sticks
+		if argc == 2 && arg[1] == "reset" {
+			for jc := range mgr.connected {
+				resetStickRanges(jc)
+			}
+			color.HiBlue("stick calibration reset to factory")
+			return
+		}
+		if argc != 1 {
+			color.HiRed("usage: stickcalib [reset]")
+			return
+		}
+		for jc := range mgr.connected {
+			calibrateSticks(jc)
+		}
+		return
+
+	case "record": // capture reports of newly connected controllers
+		mgr.mu.Lock()
+		defer mgr.mu.Unlock()
+
+		switch argc {
+		case 1:
+			mgr.recordDir = ""
+			color.HiBlue("recording stopped for new connections")
+		case 2:
+			mgr.recordDir = arg[1]
+			color.HiBlue("newly connected controllers will be recorded to: %s", arg[1])
+		default:
+			color.HiRed("usage: record [dir]\n e.g. record /tmp\n 'record' without dir to stop")
+		}
+		return
+	case "replay": // replay a capture file as a controller
+		if argc < 2 || argc > 3 || (argc == 3 && arg[2] != "fast") {
+			color.HiRed("usage: replay file [fast]\n e.g. replay /tmp/7048f776bc87_20221017_120000.txt")
+			return
+		}
+		if e := mgr.replay(arg[1], argc == 2); e != nil {
+			color.HiRed("replay fail: %s", e.Error())
+		}
+		return
 
 	case "gyro": // toggle gyro printing
 		gyro = !gyro
@@ -88,9 +198,18 @@ func executor(in string) {
 		}
 		return
 
-	case "rumble": // make JoyCon viberate for 32 frames.
+	case "rumble": // play a vibration pattern, e.g. "rumble double"
+		name := "tick"
+		if argc > 1 {
+			name = arg[1]
+		}
+		pattern, ok := joycon.RumblePatterns[name]
+		if !ok {
+			color.HiRed("unknown rumble pattern: %s", name)
+			return
+		}
 		for jc := range mgr.connected {
-			jc.Rumble(nil)
+			jc.PlayRumble(pattern)
 		}
 		return
 	case "test": // make JoyCon viberate for 32 frames.
@@ -119,6 +238,11 @@ func main() {
 		return
 	}
 
+	// data of known controllers
+	if e := store.load(); e != nil {
+		log.Errorf("load '%s' error: %s", StoreFile, e.Error())
+	}
+
 	// watch config file change
 	stopWatch := watchConfig()
 	defer func() { stopWatch <- struct{}{} }()
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,25 +13,64 @@ import (
 	"github.com/aj3423/joy-typing/mode"
 	"github.com/gen2brain/beeep"
 	log "github.com/sirupsen/logrus"
-	"github.com/sstallion/go-hid"
 )
 
 type Manager struct {
 	mu sync.Mutex
 
 	connected map[joycon.Controller]joycon.RemoveListenerFn
+
+	// if set, all reports of newly connected controllers are captured to this directory
+	recordDir string
+
+	// controllers opened from each hid path, including the charging grip slots,
+	// a half of the Paired one is indexed by its own path
+	paths map[string]joycon.Controller
+
+	// the last discovery result of each hid path, only changes are logged
+	reported map[string]string
+
+	// charging grip slots without Joy-Con, by hid path
+	emptySlots map[string]emptySlot
+
+	// opened devices of the 'KeyDevice' section, by name
+	keyDevices map[string]*keyDevice
+
+	// stick directions of each controller, [left stick, right stick]
+	muDirs     sync.Mutex
+	directions map[joycon.Controller]*[2]*joycon.DirectionTracker
+
+	// states of removed controllers, indexed by MAC, restored when they reconnect
+	states map[string]*joycon.State
 }
 
 func NewManager() *Manager {
 	return &Manager{
 		connected: make(map[joycon.Controller]joycon.RemoveListenerFn),
+		paths:     make(map[string]joycon.Controller),
+		reported:  make(map[string]string),
+
+		emptySlots: make(map[string]emptySlot),
+
+		keyDevices: make(map[string]*keyDevice),
+
+		directions: make(map[joycon.Controller]*[2]*joycon.DirectionTracker),
+		states:     make(map[string]*joycon.State),
 	}
 }
 
 func (m *Manager) monitorNewDevice(chExit_Ctrl_d chan struct{}) {
+	m.CheckNewDevice()
 
-	// scan for newly connected device every second
-	tickNewDevice := time.NewTicker(1 * time.Second)
+	// React to the device nodes on Linux, polling is still needed for
+	// the Joy-Con inserted into the charging grip, it doesn't add any node.
+	interval := 1 * time.Second
+	if e := watchDevNodes(func() { m.CheckNewDevice() }); e != nil {
+		log.Debugf("device node watcher: %s, polling instead", e.Error())
+	} else {
+		interval = 5 * time.Second
+	}
+	tickNewDevice := time.NewTicker(interval)
 
 	for {
 		select {
@@ -41,19 +84,37 @@ func (m *Manager) monitorNewDevice(chExit_Ctrl_d chan struct{}) {
 	}
 }
 
+// pass the input to the mode session of its controller
+func (m *Manager) handle(in *mode.Input) {
+	mm, e := sessions.of(in.Jc)
+	if e != nil {
+		log.Errorf("%s: %s", in.Jc.Mac(), e.Error())
+		return
+	}
+	mm.Handle(in)
+}
+
 func (m *Manager) OnReadWriteError(
 	jc joycon.Controller, err error,
 ) {
 	log.Errorf("%s: %s", jc.Side().String(), err.Error())
 	go beeep.Notify("R/W error", jc.Side().String(), "")
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	// If any r/w error occurs, the connection must be broken,
 	// but the zombie connection still stays in system BT manager for a while,
 	// so forcely disconnect it to avoid that period of time.
-	go m.remove(jc, true)
+	go func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		// only the failing half of a paired one, the other half stays connected
+		var half *joycon.HalfError
+		if p, ok := jc.(*joycon.Paired); ok && errors.As(err, &half) {
+			m.unpair(p)
+			jc = half.Half
+		}
+		m.remove(jc, true)
+	}()
 }
 func (m *Manager) OnButton(
 	jc joycon.Controller,
@@ -61,7 +122,7 @@ func (m *Manager) OnButton(
 ) {
 	log.Tracef("onButton, down: %v, up: %v", down, up)
 
-	mode.Manager.Handle(
+	m.handle(
 		&mode.Input{
 			Type: mode.InputType_Button,
 
@@ -73,17 +134,24 @@ func (m *Manager) OnButton(
 	)
 }
 
-func isGoingNeutral(curr, prev *joycon.Ratio) bool {
-	return !prev.AtNeutral() && curr.AtNeutral()
-}
-func isLeavingNeutral(curr, prev *joycon.Ratio) bool {
-	return prev.AtNeutral() && !curr.AtNeutral()
-}
-func isGoingEdge(curr, prev *joycon.Ratio, dir joycon.SpinDirection) bool {
-	return !prev.AtEdge(dir) && curr.AtEdge(dir)
-}
-func isLeavingEdge(curr, prev *joycon.Ratio, dir joycon.SpinDirection) bool {
-	return prev.AtEdge(dir) && !curr.AtEdge(dir)
+// the direction tracker of the stick, created at the first use
+func (m *Manager) directionTracker(
+	jc joycon.Controller, side joycon.JoyConSide,
+) *joycon.DirectionTracker {
+	m.muDirs.Lock()
+	defer m.muDirs.Unlock()
+
+	trackers, ok := m.directions[jc]
+	if !ok {
+		trackers = &[2]*joycon.DirectionTracker{
+			joycon.NewDirectionTracker(), joycon.NewDirectionTracker(),
+		}
+		m.directions[jc] = trackers
+	}
+	if side == joycon.SideLeft {
+		return trackers[0]
+	}
+	return trackers[1]
 }
 
 func (m *Manager) OnStick(
@@ -93,10 +161,10 @@ func (m *Manager) OnStick(
 
 	// fire 2 events:
 	// 1. a movement event
-	// 2. a edge-movement event, e.g. Up, Upleave
+	// 2. direction events, e.g. Up, UpLeave, UpLeft
 
 	// 1.
-	mode.Manager.Handle(
+	m.handle(
 		&mode.Input{
 			Type: mode.InputType_Stick,
 
@@ -110,33 +178,9 @@ func (m *Manager) OnStick(
 		},
 	)
 
-	// 2.
-	dir := joycon.SpinDirection_None
-
-	if isGoingEdge(curr, prev, joycon.Spin_Up) { // spin Up
-		dir = joycon.Spin_Up
-	} else if isLeavingEdge(curr, prev, joycon.Spin_Up) { // leave Up area
-		dir = joycon.Spin_Up_Leave
-	} else if isGoingEdge(curr, prev, joycon.Spin_Right) {
-		dir = joycon.Spin_Right
-	} else if isLeavingEdge(curr, prev, joycon.Spin_Right) {
-		dir = joycon.Spin_Right_Leave
-	} else if isGoingEdge(curr, prev, joycon.Spin_Down) {
-		dir = joycon.Spin_Down
-	} else if isLeavingEdge(curr, prev, joycon.Spin_Down) {
-		dir = joycon.Spin_Down_Leave
-	} else if isGoingEdge(curr, prev, joycon.Spin_Left) {
-		dir = joycon.Spin_Left
-	} else if isLeavingEdge(curr, prev, joycon.Spin_Left) {
-		dir = joycon.Spin_Left_Leave
-	} else if isGoingNeutral(curr, prev) {
-		dir = joycon.Spin_Neutral
-	} else if isLeavingNeutral(curr, prev) {
-		dir = joycon.Spin_Neutral_Leave
-	}
-
-	if dir != joycon.SpinDirection_None {
-		mode.Manager.Handle(
+	// 2. leave the previous direction, then enter the new one
+	for _, dir := range m.directionTracker(jc, side).Update(curr) {
+		m.handle(
 			&mode.Input{
 				Type: mode.InputType_Stick,
 
@@ -150,7 +194,6 @@ func (m *Manager) OnStick(
 			},
 		)
 	}
-
 }
 func (m *Manager) OnStickCalib(
 	jc joycon.Controller, calib *[2]joycon.CalibrationData,
@@ -158,12 +201,23 @@ func (m *Manager) OnStickCalib(
 	log.Infof("🔧 <%s> Calibrated: %v", jc.Side().String(), calib)
 	go beeep.Notify("🔧 Calibrated", jc.Side().String(), "")
 }
-func (m *Manager) OnGyro(jc joycon.Controller, gyro *joycon.GyroFrame) {
+func (m *Manager) OnStickDrift(
+	jc joycon.Controller, side joycon.JoyConSide, offset *joycon.Ratio,
+) {
+	log.Infof("🎯 <%s> %s stick drift corrected: %s", jc.Side().String(), side.String(), offset)
+	go beeep.Notify("🎯 Stick re-centered", jc.Side().String(), "")
+}
+func (m *Manager) OnGyro(
+	jc joycon.Controller, side joycon.JoyConSide, gyro *joycon.GyroFrame,
+) {
 	log.Tracef("onGyro, %v", *gyro)
-	mode.Manager.Handle(
+	m.handle(
 		&mode.Input{
 			Type: mode.InputType_Gyro,
+
+			Jc: jc,
 			Gyro: &mode.Gyro{
+				Side:  side,
 				Frame: gyro,
 			},
 		},
@@ -192,14 +246,69 @@ func (m *Manager) remove(
 	log.Warningf("Removing %s (%s) ...", jc.Side().String(), jc.Mac())
 
 	// unbind events
-	unbind := m.connected[jc]
+	unbind, ok := m.connected[jc]
+	if !ok { // already removed
+		return
+	}
 	unbind()
 
+	saveController(jc)
+
+	for _, half := range halvesOf(jc) {
+		if dev, ok := half.(joycon.Device); ok {
+			m.states[half.Mac()] = dev.State()
+		}
+	}
+
 	if disconnectBT {
 		jc.ShutdownBT()
 	}
 	jc.Disconnect()
 	delete(m.connected, jc)
+
+	m.muDirs.Lock()
+	delete(m.directions, jc)
+	m.muDirs.Unlock()
+
+	for _, half := range halvesOf(jc) {
+		for path, c := range m.paths {
+			if c == half {
+				delete(m.paths, path)
+			}
+		}
+	}
+}
+
+// a copy of the connected controllers
+func (m *Manager) controllers() []joycon.Controller {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ret := make([]joycon.Controller, 0, len(m.connected))
+	for jc := range m.connected {
+		ret = append(ret, jc)
+	}
+	return ret
+}
+
+// Split the paired one, both halves become standalone controllers
+func (m *Manager) unpair(p *joycon.Paired) {
+	unbind, ok := m.connected[p]
+	if !ok { // already removed
+		return
+	}
+	unbind()
+	delete(m.connected, p)
+
+	m.muDirs.Lock()
+	delete(m.directions, p)
+	m.muDirs.Unlock()
+
+	p.Split()
+	for _, half := range halvesOf(p) {
+		m.connected[half] = half.Subscribe(m)
+	}
+	log.Infof("Unpaired: %s", p.Mac())
 }
 
 func (m *Manager) removeAll() {
@@ -210,53 +319,128 @@ func (m *Manager) removeAll() {
 	for jc := range m.connected {
 		m.remove(jc, false)
 	}
+	for _, kd := range m.keyDevices {
+		m.closeKeyDevice(kd)
+	}
 }
 
-// find which side is not connected yet
-func (m *Manager) findBySide(side joycon.JoyConSide) joycon.Controller {
+// the first connected Joy-Con of this side that isn't paired yet, with the same alias
+func (m *Manager) findUnpaired(side joycon.JoyConSide, alias string) joycon.Controller {
 	for jc := range m.connected {
-		if jc.Side() == side {
+		if _, ok := jc.(*joycon.Paired); ok {
+			continue
+		}
+		if jc.Side() == side && sessions.aliasOf(jc) == alias {
 			return jc
 		}
 	}
 	return nil
 }
 
-// corresponding side <-> products
-var sides = []joycon.JoyConSide{joycon.SideLeft, joycon.SideRight}
-var products = []uint16{joycon.JOYCON_PRODUCT_L, joycon.JOYCON_PRODUCT_R}
+// restore the state if it's reconnecting
+func (m *Manager) newController(
+	transport joycon.Transport, side joycon.JoyConSide, mac string,
+) joycon.Controller {
+	state, ok := m.states[mac]
+	if !ok {
+		return joycon.NewJoycon(transport, side, mac)
+	}
+	delete(m.states, mac)
 
-func (m *Manager) CheckNewDevice() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	// the mode may have changed while it's disconnected
+	if mm, e := sessions.ofMac(mac); e == nil {
+		_, state.GyroOn = mm.CurrentMode().(*mode.GyroMode)
+	}
 
-	for i, side := range sides {
-		if m.findBySide(side) != nil { // already exists
-			continue
-		}
+	log.Infof("Reconnecting: <%s> %s, restoring state", side, mac)
+	return joycon.RestoreJoycon(transport, side, mac, state)
+}
 
-		dev, e := hid.OpenFirst(joycon.VENDOR_NINTENDO, products[i])
-		if e != nil {
-			continue
-		}
+func (m *Manager) addNewDevice(jc joycon.Controller) {
+	restoreController(jc)
+
+	unbindFn := jc.Subscribe(m)
+	m.connected[jc] = unbindFn
+	log.Infof("Connected to: <%s> %s", jc.Side(), jc.Mac())
+	go beeep.Notify("Connected", jc.Side().String(), "")
 
-		mac, e := dev.GetSerialNbr()
-		if e != nil {
-			dev.Close()
+	jc.PlayRumble(joycon.RumblePatterns["connected"])
+	if mm, e := sessions.of(jc); e == nil {
+		go mm.CurrentMode().ApplyLights(jc)
+	} else {
+		log.Errorf("%s: %s", jc.Mac(), e.Error())
+	}
+
+	if currCfg.PairJoycons {
+		m.pairJoycons()
+	}
+}
+
+// Combine the left and right Joy-Cons into one controller if both are connected,
+// only the ones with the same alias, or both without alias, are combined.
+func (m *Manager) pairJoycons() {
+	var left, right joycon.Controller
+	for jc := range m.connected {
+		if jc.Side() != joycon.SideLeft {
 			continue
 		}
+		if right = m.findUnpaired(joycon.SideRight, sessions.aliasOf(jc)); right != nil {
+			left = jc
+			break
+		}
+	}
+	if left == nil {
+		return
+	}
+	for _, jc := range []joycon.Controller{left, right} {
+		m.connected[jc]() // unbind, the paired one listens to them
+		delete(m.connected, jc)
+	}
 
-		jc := joycon.NewJoycon(dev, side, mac)
-		m.addNewDevice(jc)
+	p := joycon.NewPaired(left, right)
+	m.connected[p] = p.Subscribe(m)
+	log.Infof("Paired: <%s> %s", p.Side(), p.Mac())
+}
 
+// wrap the transport with a recorder, it stays unwrapped if failed to create the capture file
+func (m *Manager) record(
+	transport joycon.Transport, side joycon.JoyConSide, mac string,
+) joycon.Transport {
+	fn := fmt.Sprintf("%s_%s.txt",
+		strings.ReplaceAll(mac, ":", ""), time.Now().Format("20060102_150405"))
+	fn = filepath.Join(m.recordDir, fn)
+
+	f, e := os.Create(fn)
+	if e != nil {
+		log.Errorf("fail to create capture file: %s", e.Error())
+		return transport
 	}
-
+	rec, e := joycon.NewRecorder(transport, f, side, mac)
+	if e != nil {
+		log.Errorf("fail to write capture file: %s", e.Error())
+		f.Close()
+		return transport
+	}
+	log.Infof("Recording <%s> to: %s", side, fn)
+	return rec
 }
-func (m *Manager) addNewDevice(jc joycon.Controller) {
-	unbindFn := jc.SetListener(m)
-	m.connected[jc] = unbindFn
-	log.Infof("Connected to: <%s> %s", jc.Side(), jc.Mac())
-	go beeep.Notify("Connected", jc.Side().String(), "")
 
-	jc.Rumble(&joycon.RumbleFrequencySample)
+// replay a capture file as a connected controller
+func (m *Manager) replay(fn string, realtime bool) error {
+	f, e := os.Open(fn)
+	if e != nil {
+		return e
+	}
+	rp, e := joycon.NewReplay(f, realtime)
+	if e != nil {
+		f.Close()
+		return e
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jc := joycon.NewJoycon(rp, rp.Side(), rp.Mac())
+	m.addNewDevice(jc)
+	return nil
 }
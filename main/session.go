@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aj3423/joy-typing/joycon"
+	"github.com/aj3423/joy-typing/mode"
+	log "github.com/sirupsen/logrus"
+)
+
+// Mode sessions, each controller switches modes independently.
+// Controllers with the same alias share one session.
+type sessionList struct {
+	mu sync.Mutex
+
+	// alias or MAC -> session, created when first used
+	byName map[string]*mode.ModeManager
+
+	aliases  map[string]string // normalized MAC -> alias
+	profiles map[string][]mode.ModeConfig
+}
+
+var sessions = &sessionList{
+	byName: make(map[string]*mode.ModeManager),
+}
+
+// the session of key devices without alias when no controller is connected
+const defaultSession = "default"
+
+// "70:48:F7:76:BC:87", "7048f776bc87" are the same
+func normalizeMac(mac string) string {
+	return strings.ToUpper(strings.NewReplacer(":", "", "-", "").Replace(mac))
+}
+
+// Drop all sessions, they're created again with the new config.
+// All profiles are parsed once to report errors early.
+func (s *sessionList) reset(cfg *Config) error {
+	for alias, list := range cfg.Profiles {
+		if _, _, e := mode.ParseModes(list); e != nil {
+			return fmt.Errorf("profile '%s': %s", alias, e.Error())
+		}
+	}
+	aliases := make(map[string]string)
+	for mac, alias := range cfg.Aliases {
+		aliases[normalizeMac(mac)] = alias
+	}
+
+	s.mu.Lock()
+	old := s.byName
+	s.byName = make(map[string]*mode.ModeManager)
+	s.aliases = aliases
+	s.profiles = cfg.Profiles
+	s.mu.Unlock()
+
+	// exit the current modes, or the microphone and gyro are left on
+	for name, mm := range old {
+		if e := mm.Reset(); e != nil {
+			log.Errorf("session %s: %s", name, e.Error())
+		}
+	}
+	return nil
+}
+
+// the alias of the first half that has one, "" if none
+func (s *sessionList) aliasOf(jc joycon.Controller) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.alias(jc)
+}
+
+func (s *sessionList) alias(jc joycon.Controller) string {
+	for _, half := range halvesOf(jc) {
+		if alias, ok := s.aliases[normalizeMac(half.Mac())]; ok {
+			return alias
+		}
+	}
+	return ""
+}
+
+// the alias, or the MAC
+func (s *sessionList) nameOf(jc joycon.Controller) string {
+	if alias := s.alias(jc); alias != "" {
+		return alias
+	}
+	return jc.Mac()
+}
+
+func (s *sessionList) of(jc joycon.Controller) (*mode.ModeManager, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.get(s.nameOf(jc))
+}
+
+// by the MAC of a controller that isn't created yet
+func (s *sessionList) ofMac(mac string) (*mode.ModeManager, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name, ok := s.aliases[normalizeMac(mac)]
+	if !ok {
+		name = mac
+	}
+	return s.get(name)
+}
+
+// The session of a key device when no controller receives its keys,
+// the one of its alias, or the default one
+func (s *sessionList) ofKeyDevice(alias string) (*mode.ModeManager, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if alias == "" {
+		alias = defaultSession
+	}
+	return s.get(alias)
+}
+
+// the alias uses its own profile, or the 'Mode' rules if it has none
+func (s *sessionList) get(name string) (*mode.ModeManager, error) {
+	if mm, ok := s.byName[name]; ok {
+		return mm, nil
+	}
+
+	list, ok := s.profiles[name]
+	if !ok {
+		list = mode.ModeList
+	}
+	modes, modeSwitches, e := mode.ParseModes(list)
+	if e != nil {
+		return nil, e
+	}
+	mm := mode.NewModeManager(name)
+	if e = mm.SetModes(modes, modeSwitches); e != nil {
+		return nil, e
+	}
+	s.byName[name] = mm
+	return mm, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aj3423/joy-typing/joycon"
+	"github.com/fatih/color"
+)
+
+const (
+	stickSampleInterval = 5 * time.Millisecond
+	stickRestDuration   = time.Second // sampling the center
+)
+
+// Measure the stick range interactively:
+// rotate the stick to the edge, then rest it at center.
+// The result is saved per MAC, and preferred over the factory calibration.
+func calibrateSticks(jc joycon.Controller) {
+	stdin := bufio.NewReader(os.Stdin)
+
+	has := [2]bool{jc.Side().IsLeft(), jc.Side().IsRight()} // [left, right]
+	var samplers [2]joycon.StickSampler
+
+	// sample in background until the returned func is called
+	sample := func(add func(*joycon.StickSampler, joycon.Point)) func() {
+		done := make(chan struct{})
+		finished := make(chan struct{})
+		go func() {
+			defer close(finished)
+
+			ticker := time.NewTicker(stickSampleInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					raw := jc.RawStick()
+					for i := range has {
+						if has[i] {
+							add(&samplers[i], raw[i])
+						}
+					}
+				}
+			}
+		}()
+		return func() {
+			close(done)
+			<-finished
+		}
+	}
+
+	color.HiBlue("<%s> %s: rotate the stick(s) along the edge slowly for a few circles, then press Enter",
+		jc.Side().String(), jc.Mac())
+	stop := sample((*joycon.StickSampler).AddRange)
+	stdin.ReadString('\n')
+	stop()
+
+	color.HiBlue("release the stick(s) and don't touch, then press Enter")
+	stdin.ReadString('\n')
+	stop = sample((*joycon.StickSampler).AddRest)
+	time.Sleep(stickRestDuration)
+	stop()
+
+	var ranges [2]*joycon.StickRange
+	for i := range has {
+		if !has[i] {
+			continue
+		}
+		r, e := samplers[i].Result()
+		if e != nil {
+			color.HiRed("stick calibration failed: %s", e.Error())
+			return
+		}
+		ranges[i] = r
+		fmt.Printf("  stick %d: %s\n", i, r)
+	}
+
+	jc.SetStickRanges(ranges)
+	saveStickRanges(jc, ranges)
+	color.HiGreen("stick calibration saved to '%s'", StoreFile)
+}
+
+// drop the measured ranges, use the factory calibration again
+func resetStickRanges(jc joycon.Controller) {
+	ranges := [2]*joycon.StickRange{}
+	jc.SetStickRanges(ranges)
+	saveStickRanges(jc, ranges)
+}
+
+// each half of the paired controller keeps its own stick
+func saveStickRanges(jc joycon.Controller, ranges [2]*joycon.StickRange) {
+	for _, half := range halvesOf(jc) {
+		side := half.Side()
+		store.update(half.Mac(), func(d *ControllerData) {
+			if side.IsLeft() {
+				d.LeftStick = ranges[0]
+			}
+			if side.IsRight() {
+				d.RightStick = ranges[1]
+			}
+		})
+	}
+}
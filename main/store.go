@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/aj3423/joy-typing/joycon"
+	"github.com/pelletier/go-toml/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// Things learned about each controller, kept across sessions.
+// Unlike `config.toml`, it's written by the app, not by the user.
+const StoreFile = "controllers.toml"
+
+type ControllerData struct {
+	GyroBias joycon.AngularVelocity `comment:"learned while resting, in deg/s"`
+
+	LeftStick  *joycon.StickRange `comment:"raw stick range measured by 'stickcalib'"`
+	RightStick *joycon.StickRange `comment:"raw stick range measured by 'stickcalib'"`
+}
+
+type Store struct {
+	mu sync.Mutex
+
+	// indexed by MAC
+	Controllers map[string]*ControllerData
+}
+
+// global variable
+var store = &Store{Controllers: make(map[string]*ControllerData)}
+
+func (s *Store) load() error {
+	b, e := ioutil.ReadFile(StoreFile)
+	if errors.Is(e, os.ErrNotExist) { // nothing saved yet
+		return nil
+	}
+	if e != nil {
+		return e
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e = toml.Unmarshal(b, s); e != nil {
+		return e
+	}
+	if s.Controllers == nil {
+		s.Controllers = make(map[string]*ControllerData)
+	}
+	return nil
+}
+
+func (s *Store) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buf := bytes.Buffer{}
+	enc := toml.NewEncoder(&buf)
+	enc.SetIndentTables(true)
+	if e := enc.Encode(s); e != nil {
+		return e
+	}
+	return ioutil.WriteFile(StoreFile, buf.Bytes(), 0644)
+}
+
+// returns nil if nothing saved for this controller
+func (s *Store) get(mac string) *ControllerData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d, ok := s.Controllers[mac]; ok {
+		copied := *d
+		return &copied
+	}
+	return nil
+}
+
+// modify the data of a controller and save to file
+func (s *Store) update(mac string, fn func(*ControllerData)) {
+	s.mu.Lock()
+	d, ok := s.Controllers[mac]
+	if !ok {
+		d = &ControllerData{}
+		s.Controllers[mac] = d
+	}
+	fn(d)
+	s.mu.Unlock()
+
+	if e := s.save(); e != nil {
+		log.Errorf("fail to save '%s': %s", StoreFile, e.Error())
+	}
+}
+
+// the paired controller keeps data for each half
+func halvesOf(jc joycon.Controller) []joycon.Controller {
+	if p, ok := jc.(*joycon.Paired); ok {
+		return []joycon.Controller{p.Left(), p.Right()}
+	}
+	return []joycon.Controller{jc}
+}
+
+// apply saved data to a newly connected controller
+func restoreController(jc joycon.Controller) {
+	d := store.get(jc.Mac())
+	if d == nil {
+		return
+	}
+	jc.SetGyroBias(d.GyroBias)
+	log.Debugf("%s: restored gyro bias: %v", jc.Mac(), d.GyroBias)
+
+	if d.LeftStick != nil || d.RightStick != nil {
+		jc.SetStickRanges([2]*joycon.StickRange{d.LeftStick, d.RightStick})
+		log.Debugf("%s: restored stick ranges: %v %v", jc.Mac(), d.LeftStick, d.RightStick)
+	}
+}
+
+// save what is learned about the controller before it's removed
+func saveController(jc joycon.Controller) {
+	for _, half := range halvesOf(jc) {
+		bias := half.GyroBias()
+		store.update(half.Mac(), func(d *ControllerData) {
+			d.GyroBias = bias
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/aj3423/joy-typing/joycon"
 	"github.com/gen2brain/beeep"
 	"github.com/go-vgo/robotgo"
 	log "github.com/sirupsen/logrus"
@@ -45,13 +46,48 @@ func robotgo_Toggle(type_, downUp string) {
 // `robotgo.MoveRelative` takes < 2ms
 type MoveCursor struct {
 
-	// a factor that can increase/decrease the cursor speed
+	// a factor that can increase/decrease the cursor speed,
+	// for stick: pixels per report at the edge,
+	// for gyro: pixels per degree of rotation
 	speed float64
+
+	// Gyro samples come every 5ms, each one only moves a fraction of pixel,
+	// accumulate them until it's a whole pixel.
+	remainX, remainY float64
 }
 
 func NewMoveCursor(speed float64) *MoveCursor {
 	return &MoveCursor{speed: speed}
 }
+
+// For gyro, `-speed` used to multiply the raw counts of each report, e.g. 0.03,
+// it's about 1000 times of that in pixels per degree, e.g. 30.
+// The old values would hardly move the cursor, they're converted.
+const (
+	legacyGyroSpeedMax    = 1
+	legacyGyroSpeedFactor = 1000
+)
+
+var (
+	muWarnedSpeed sync.Mutex
+	warnedSpeed   = map[string]bool{} // rules already warned, they're parsed for each session
+)
+
+func (mc *MoveCursor) upgradeGyroSpeed(rule string) {
+	if mc.speed <= 0 || mc.speed >= legacyGyroSpeedMax {
+		return
+	}
+	old := mc.speed
+	mc.speed *= legacyGyroSpeedFactor
+
+	muWarnedSpeed.Lock()
+	defer muWarnedSpeed.Unlock()
+	if !warnedSpeed[rule] {
+		warnedSpeed[rule] = true
+		log.Warningf("'%s': gyro cursor speed is pixels per degree now, %g is taken as %g, please update the config",
+			rule, old, mc.speed)
+	}
+}
 func (mc *MoveCursor) Do(in *Input) {
 	switch in.Type {
 	case InputType_Stick:
@@ -61,10 +97,18 @@ func (mc *MoveCursor) Do(in *Input) {
 		)
 
 	case InputType_Gyro:
-		go robotgo_MoveRelative(
-			int(float64(in.Frame.Yaw)*mc.speed),
-			int(-float64(in.Frame.Pitch)*mc.speed),
-		)
+		// degrees rotated since previous sample
+		dt := in.Frame.Interval.Seconds()
+		mc.remainX += in.Frame.Rotation.Yaw * dt * mc.speed
+		mc.remainY += -in.Frame.Rotation.Pitch * dt * mc.speed
+
+		x, y := int(mc.remainX), int(mc.remainY)
+		if x == 0 && y == 0 {
+			return
+		}
+		mc.remainX -= float64(x)
+		mc.remainY -= float64(y)
+		go robotgo_MoveRelative(x, y)
 	}
 }
 
@@ -92,9 +136,12 @@ func NewSwitchMode(modeId string) *SwitchMode {
 }
 
 func (sm *SwitchMode) Do(in *Input) {
-	e := Manager.switchTo(sm.modeId, in)
+	e := in.mgr.switchTo(sm.modeId, in)
 	if e != nil {
 		go beeep.Alert("failed to switch to mode "+sm.modeId, e.Error(), "")
+		if in.Jc != nil {
+			in.Jc.PlayRumble(joycon.RumblePatterns["error"])
+		}
 	}
 }
 
@@ -102,7 +149,7 @@ func (sm *SwitchMode) Do(in *Input) {
 type RestoreMode struct{}
 
 func (rm *RestoreMode) Do(in *Input) {
-	Manager.switchTo(Manager.defaultMode.Id(), in)
+	in.mgr.switchTo(in.mgr.defaultMode.Id(), in)
 }
 
 // Popup a system notification with specified Title/Text/Icon
@@ -127,8 +174,8 @@ func (sn *SysNotify) Do(in *Input) {
 // further data and return the result immediately
 type FlushVoice struct{}
 
-func (fv *FlushVoice) Do(*Input) {
-	switch sp := Manager.currentMode.(type) {
+func (fv *FlushVoice) Do(in *Input) {
+	switch sp := in.mgr.currentMode.(type) {
 	case *SpeechMode:
 		go sp.recEngine.Flush()
 	}
@@ -143,8 +190,8 @@ type Speak struct {
 	text string
 }
 
-func (s *Speak) Do(*Input) {
-	go Manager.Handle(&Input{
+func (s *Speak) Do(in *Input) {
+	go in.mgr.Handle(&Input{
 		Type: InputType_Speech,
 		SpeechInput: &SpeechInput{
 			Text: s.text,
@@ -158,7 +205,28 @@ type EnableGyro struct {
 }
 
 func (eg *EnableGyro) Do(in *Input) {
-	go in.Jc.EnableGyro(eg.enable)
+	if in.Jc != nil { // nil for speech or a key device without controller
+		go in.Jc.EnableGyro(eg.enable)
+	}
+}
+
+// Haptic feedback with a named vibration pattern
+type Rumble struct {
+	pattern joycon.RumblePattern
+}
+
+func NewRumble(name string) (*Rumble, error) {
+	pattern, ok := joycon.RumblePatterns[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown rumble pattern: %s", name)
+	}
+	return &Rumble{pattern}, nil
+}
+
+func (r *Rumble) Do(in *Input) {
+	if in.Jc != nil { // nil for speech
+		in.Jc.PlayRumble(r.pattern)
+	}
 }
 
 type MouseToggle struct {
@@ -193,18 +261,16 @@ func NewHotkey(keys []string) *Hotkey {
 	return h
 }
 
-// used for Repeat
-var lastSpeech string
-
+// repeat the last speech of the session
 type Repeat struct {
 }
 
-func (r *Repeat) Do(*Input) {
-	if len(lastSpeech) > 0 {
-		go Manager.Handle(&Input{
+func (r *Repeat) Do(in *Input) {
+	if len(in.mgr.lastSpeech) > 0 {
+		go in.mgr.Handle(&Input{
 			Type: InputType_Speech,
 			SpeechInput: &SpeechInput{
-				Text: lastSpeech,
+				Text: in.mgr.lastSpeech,
 			},
 		})
 	}
@@ -336,8 +402,8 @@ func (es *ExecSpeech) Do(in *Input) {
 	}
 
 	// save text for [repeat]
-	if !containsRepeat(newWords) { // not being with `[repeat]`
-		lastSpeech = in.Text
+	if !containsRepeat(newWords) && in.mgr != nil { // not being with `[repeat]`
+		in.mgr.lastSpeech = in.Text
 	}
 
 	// 3. generate executor array from word array
@@ -395,6 +461,9 @@ func (es *ExecSpeech) Do(in *Input) {
 			ex.decorators = modifiers
 			modifiers = nil
 			g.exec(nil)
+		case *repeat:
+			ex.mgr = in.mgr
+			g.exec(nil)
 		default:
 			g.exec(nil)
 		}
@@ -1,6 +1,8 @@
 package mode
 
 import (
+	"time"
+
 	"github.com/aj3423/joy-typing/joycon"
 )
 
@@ -17,12 +19,20 @@ type ButtonCondition struct {
 
 	// the target button
 	btnId joycon.ButtonID
+
+	// other buttons that must be held down together, for chords like ZL + ZR
+	with []joycon.ButtonID
 }
 
 func (bc *ButtonCondition) Satisfy(in *Input) bool {
 	if in.Type != InputType_Button {
 		return false
 	}
+	for _, w := range bc.with {
+		if !in.Curr.Has(w) {
+			return false
+		}
+	}
 	if bc.whenDown && in.Down.Has(bc.btnId) {
 		return true
 	}
@@ -32,6 +42,20 @@ func (bc *ButtonCondition) Satisfy(in *Input) bool {
 	return false
 }
 
+// a key of other input devices
+type KeyCondition struct {
+	device   string // any device if empty
+	code     uint16
+	whenDown bool
+}
+
+func (kc *KeyCondition) Satisfy(in *Input) bool {
+	return in.Type == InputType_Key &&
+		(kc.device == "" || kc.device == in.KeyInput.Device) &&
+		in.KeyInput.Code == kc.code &&
+		in.KeyInput.Pressed == kc.whenDown
+}
+
 // check if there is specified stick movement event
 type StickMoveCondition struct {
 	side joycon.JoyConSide
@@ -39,11 +63,11 @@ type StickMoveCondition struct {
 
 func (sc *StickMoveCondition) Satisfy(in *Input) bool {
 	return in.Type == InputType_Stick &&
-		in.Side == sc.side &&
+		in.StickInput.Side == sc.side &&
 		in.Direction == joycon.SpinDirection_None
 }
 
-// 5 enter and 5 leave events for: U D L R Center
+// 9 enter and 9 leave events for: U D L R, the 4 diagonals and Center
 type StickDirectionCondition struct {
 	side joycon.JoyConSide
 	dir  joycon.SpinDirection
@@ -51,10 +75,33 @@ type StickDirectionCondition struct {
 
 func (sc *StickDirectionCondition) Satisfy(in *Input) bool {
 	return in.Type == InputType_Stick &&
-		in.Side == sc.side &&
+		in.StickInput.Side == sc.side &&
 		in.Direction == sc.dir
 }
 
+// a motion pattern of the stick, like flick or rotation
+type StickGestureCondition struct {
+	side       joycon.JoyConSide
+	newGesture func() gesture
+
+	gestures map[sourceKey]gesture // one recognizer per stick
+}
+
+func (sc *StickGestureCondition) Satisfy(in *Input) bool {
+	if in.Type != InputType_Stick ||
+		in.StickInput.Side != sc.side ||
+		in.Direction != joycon.SpinDirection_None {
+		return false
+	}
+	key := sourceKey{jc: in.Jc, side: in.StickInput.Side}
+	g, ok := sc.gestures[key]
+	if !ok {
+		g = sc.newGesture()
+		sc.gestures[key] = g
+	}
+	return g.feed(in.Ratio, time.Now())
+}
+
 // return true if there is speech signal
 type SpeechCondition struct{}
 
@@ -63,8 +110,92 @@ func (sc *SpeechCondition) Satisfy(in *Input) bool {
 }
 
 // return true if there is gyro signal
-type GyroCondition struct{}
+type GyroCondition struct {
+	// SideInvalid for any side
+	side joycon.JoyConSide
+}
+
+func (gc *GyroCondition) Satisfy(in *Input) bool {
+	if in.Type != InputType_Gyro {
+		return false
+	}
+	return gyroSideMatch(gc.side, in)
+}
+
+// SideInvalid matches any side, SideBoth(the Pro Controller) matches either side
+func gyroSideMatch(side joycon.JoyConSide, in *Input) bool {
+	return side == joycon.SideInvalid || in.Gyro.Side&side != 0
+}
 
-func (sc *GyroCondition) Satisfy(in *Input) bool {
-	return in.Type == InputType_Gyro
+// Orientation axes for tilt rules
+var TiltAxes = map[string]func(*joycon.Orientation) float64{
+	"roll":  func(o *joycon.Orientation) float64 { return o.Roll },
+	"pitch": func(o *joycon.Orientation) float64 { return o.Pitch },
+	"yaw":   func(o *joycon.Orientation) float64 { return o.Yaw },
+}
+
+// Each stick or IMU is tracked separately,
+// like both halves of a paired controller or the controllers sharing a session.
+type sourceKey struct {
+	jc   joycon.Controller
+	side joycon.JoyConSide
+}
+
+// Whether each IMU is in an angle range, e.g. roll in 30~90 means
+// "tilted 30 degrees or more to the right".
+// The on/off triggers of a switch share one, it's updated once per input.
+type tiltRange struct {
+	side     joycon.JoyConSide
+	angle    func(*joycon.Orientation) float64
+	min, max float64
+
+	inside map[sourceKey]bool
+
+	last          *Input // the input `entered` and `left` are for
+	entered, left bool
+}
+
+func newTiltRange(side joycon.JoyConSide, axis string, min, max float64) *tiltRange {
+	return &tiltRange{
+		side: side, angle: TiltAxes[axis], min: min, max: max,
+		inside: make(map[sourceKey]bool),
+	}
+}
+
+func (r *tiltRange) update(in *Input) {
+	if in == r.last {
+		return
+	}
+	r.last = in
+	r.entered, r.left = false, false
+
+	if in.Type != InputType_Gyro || !gyroSideMatch(r.side, in) {
+		return
+	}
+	angle := r.angle(&in.Frame.Orientation)
+
+	key := sourceKey{jc: in.Jc, side: in.Gyro.Side}
+	wasInside := r.inside[key]
+	inside := angle >= r.min && angle <= r.max
+	r.inside[key] = inside
+
+	r.entered = !wasInside && inside
+	r.left = wasInside && !inside
+}
+
+// Satisfied when the controller is tilted into/out of the range
+type TiltCondition struct {
+	*tiltRange
+
+	// if 'true', it responds to entering the range,
+	// and 'false' for leaving
+	whenEnter bool
+}
+
+func (tc *TiltCondition) Satisfy(in *Input) bool {
+	tc.update(in)
+	if tc.whenEnter {
+		return tc.entered
+	}
+	return tc.left
 }
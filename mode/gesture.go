@@ -0,0 +1,199 @@
+package mode
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/aj3423/joy-typing/joycon"
+)
+
+// Default time windows of gestures
+const (
+	FlickWindow    = 300 * time.Millisecond  // leave neutral -> edge -> back to neutral
+	RotationWindow = 1000 * time.Millisecond // a full circle
+	SequenceWindow = 500 * time.Millisecond  // between 2 steps
+)
+
+// The stick must be this far from center when rotating
+const rotationMinDistance = 0.5
+
+// Recognizes a motion pattern from the stick movement
+type gesture interface {
+	// returns true when the gesture is completed
+	feed(r *joycon.Ratio, now time.Time) bool
+}
+
+// name -> gesture, `dir` and `seq` are only used by some of them
+func newGesture(
+	name string, dir string, seq []string, window time.Duration,
+) (gesture, error) {
+	switch name {
+	case "flick":
+		d, ok := joycon.SpinDirectionMap[dir]
+		if !ok || joycon.ReverseDirectionMap[d] == joycon.SpinDirection_None || d == joycon.Spin_Neutral {
+			return nil, fmt.Errorf("flick needs a direction, e.g. '-dir Up', got: '%s'", dir)
+		}
+		if window == 0 {
+			window = FlickWindow
+		}
+		return &flickGesture{dir: d, window: window, tracker: joycon.NewDirectionTracker()}, nil
+
+	case "cw", "ccw":
+		if window == 0 {
+			window = RotationWindow
+		}
+		return &rotationGesture{clockwise: name == "cw", window: window}, nil
+
+	case "sequence":
+		if len(seq) < 2 {
+			return nil, fmt.Errorf("sequence needs at least 2 directions, e.g. '-seq Up Right'")
+		}
+		steps := []joycon.SpinDirection{}
+		for _, s := range seq {
+			d, ok := joycon.SpinDirectionMap[s]
+			if !ok || joycon.ReverseDirectionMap[d] == joycon.SpinDirection_None || d == joycon.Spin_Neutral {
+				return nil, fmt.Errorf("invalid direction in sequence: %s", s)
+			}
+			steps = append(steps, d)
+		}
+		if window == 0 {
+			window = SequenceWindow
+		}
+		return &sequenceGesture{steps: steps, window: window, tracker: joycon.NewDirectionTracker()}, nil
+	}
+	return nil, fmt.Errorf("unknown gesture: %s, should be flick/cw/ccw/sequence", name)
+}
+
+// A quick push to the edge in one direction and back to neutral
+type flickGesture struct {
+	dir    joycon.SpinDirection
+	window time.Duration
+
+	tracker *joycon.DirectionTracker
+	start   time.Time            // when it leaves neutral
+	visited joycon.SpinDirection // the edge direction reached, None if not yet
+	valid   bool                 // false if more than one direction reached
+}
+
+func (g *flickGesture) feed(r *joycon.Ratio, now time.Time) bool {
+	for _, ev := range g.tracker.Update(r) {
+		switch ev {
+		case joycon.Spin_Neutral_Leave:
+			g.start, g.visited, g.valid = now, joycon.SpinDirection_None, true
+		case joycon.Spin_Neutral:
+			if g.valid && g.visited == g.dir && now.Sub(g.start) <= g.window {
+				g.valid = false
+				return true
+			}
+		default:
+			if _, isEnter := joycon.ReverseDirectionMap[ev]; !isEnter {
+				continue // leave events
+			}
+			if g.visited != joycon.SpinDirection_None && g.visited != ev {
+				g.valid = false
+			}
+			g.visited = ev
+		}
+	}
+	return false
+}
+
+// A full circle along the edge
+type rotationGesture struct {
+	clockwise bool
+	window    time.Duration
+
+	start     time.Time
+	prevAngle float64
+	total     float64 // degrees rotated since `start`, counter-clockwise is positive
+	rotating  bool
+}
+
+func (g *rotationGesture) reset() {
+	g.rotating, g.total = false, 0
+}
+
+func (g *rotationGesture) feed(r *joycon.Ratio, now time.Time) bool {
+	if math.Hypot(r.X, r.Y) < rotationMinDistance {
+		g.reset()
+		return false
+	}
+	angle := math.Atan2(r.Y, r.X) * 180 / math.Pi
+
+	if !g.rotating || now.Sub(g.start) > g.window {
+		g.rotating, g.start, g.prevAngle, g.total = true, now, angle, 0
+		return false
+	}
+
+	delta := angle - g.prevAngle
+	// unwrap, e.g. 170 -> -170 is 20 degrees, not -340
+	if delta > 180 {
+		delta -= 360
+	} else if delta < -180 {
+		delta += 360
+	}
+	g.prevAngle = angle
+
+	if g.clockwise {
+		delta = -delta
+	}
+	if delta < 0 { // turning back, start over
+		g.start, g.total = now, 0
+		return false
+	}
+	g.total += delta
+
+	if g.total >= 360 {
+		g.reset()
+		return true
+	}
+	return false
+}
+
+// Directions reached one after another, like Up -> Right.
+// Directions not in the sequence are ignored, e.g. passing UpRight between Up and Right.
+type sequenceGesture struct {
+	steps  []joycon.SpinDirection
+	window time.Duration // max time between 2 steps
+
+	tracker *joycon.DirectionTracker
+	next    int // index of the next step
+	last    time.Time
+}
+
+func (g *sequenceGesture) contains(dir joycon.SpinDirection) bool {
+	for _, s := range g.steps {
+		if s == dir {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *sequenceGesture) feed(r *joycon.Ratio, now time.Time) bool {
+	for _, ev := range g.tracker.Update(r) {
+		if !g.contains(ev) {
+			continue
+		}
+		if g.next > 0 && now.Sub(g.last) > g.window { // too slow
+			g.next = 0
+		}
+		switch ev {
+		case g.steps[g.next]:
+			g.next++
+		case g.steps[0]:
+			g.next = 1
+		default:
+			g.next = 0
+			continue
+		}
+		g.last = now
+
+		if g.next == len(g.steps) {
+			g.next = 0
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package mode
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/aj3423/joy-typing/joycon"
+	"github.com/stretchr/testify/assert"
+)
+
+// feed points one by one with `step` between them,
+// returns how many times the gesture is completed
+func feedGesture(g gesture, step time.Duration, points ...joycon.Ratio) int {
+	now := time.Now()
+	n := 0
+	for i := range points {
+		if g.feed(&points[i], now) {
+			n++
+		}
+		now = now.Add(step)
+	}
+	return n
+}
+
+func polar(dist, angle float64) joycon.Ratio {
+	rad := angle * math.Pi / 180
+	return joycon.Ratio{X: dist * math.Cos(rad), Y: dist * math.Sin(rad)}
+}
+
+func TestFlickGesture(t *testing.T) {
+	newFlick := func() gesture {
+		g, e := newGesture("flick", "Up", nil, 0)
+		assert.Nil(t, e)
+		return g
+	}
+	up := []joycon.Ratio{polar(0.5, 90), polar(1, 90), polar(0.5, 90), {}}
+
+	assert.Equal(t, 1, feedGesture(newFlick(), 15*time.Millisecond, up...))
+	// too slow
+	assert.Equal(t, 0, feedGesture(newFlick(), 200*time.Millisecond, up...))
+	// other direction
+	assert.Equal(t, 0, feedGesture(newFlick(), 15*time.Millisecond,
+		polar(1, 0), joycon.Ratio{}))
+	// reached another direction on the way
+	assert.Equal(t, 0, feedGesture(newFlick(), 15*time.Millisecond,
+		polar(1, 90), polar(1, 0), joycon.Ratio{}))
+
+	_, e := newGesture("flick", "", nil, 0)
+	assert.NotNil(t, e)
+}
+
+func TestRotationGesture(t *testing.T) {
+	circle := func(from, to, step float64) (ret []joycon.Ratio) {
+		for a := from; (step > 0 && a <= to) || (step < 0 && a >= to); a += step {
+			ret = append(ret, polar(1, a))
+		}
+		return
+	}
+
+	ccw, _ := newGesture("ccw", "", nil, 0)
+	assert.Equal(t, 1, feedGesture(ccw, 15*time.Millisecond, circle(0, 380, 20)...))
+
+	cw, _ := newGesture("cw", "", nil, 0)
+	assert.Equal(t, 0, feedGesture(cw, 15*time.Millisecond, circle(0, 380, 20)...))
+	assert.Equal(t, 1, feedGesture(cw, 15*time.Millisecond, circle(90, -290, -20)...))
+
+	// too slow
+	slow, _ := newGesture("cw", "", nil, 500*time.Millisecond)
+	assert.Equal(t, 0, feedGesture(slow, 50*time.Millisecond, circle(90, -290, -20)...))
+
+	// released in the middle
+	ccw, _ = newGesture("ccw", "", nil, 0)
+	points := append(circle(0, 180, 20), joycon.Ratio{})
+	points = append(points, circle(180, 360, 20)...)
+	assert.Equal(t, 0, feedGesture(ccw, 15*time.Millisecond, points...))
+}
+
+func TestSequenceGesture(t *testing.T) {
+	newSeq := func() gesture {
+		g, e := newGesture("sequence", "", []string{"Up", "Right"}, 0)
+		assert.Nil(t, e)
+		return g
+	}
+
+	// through neutral
+	assert.Equal(t, 1, feedGesture(newSeq(), 100*time.Millisecond,
+		polar(1, 90), joycon.Ratio{}, polar(1, 0), joycon.Ratio{}))
+	// along the edge, passing UpRight
+	assert.Equal(t, 1, feedGesture(newSeq(), 50*time.Millisecond,
+		polar(1, 90), polar(1, 45), polar(1, 0)))
+	// wrong order
+	assert.Equal(t, 0, feedGesture(newSeq(), 100*time.Millisecond,
+		polar(1, 0), joycon.Ratio{}, polar(1, 90)))
+	// too slow
+	assert.Equal(t, 0, feedGesture(newSeq(), 400*time.Millisecond,
+		polar(1, 90), joycon.Ratio{}, polar(1, 0)))
+
+	_, e := newGesture("sequence", "", []string{"Up"}, 0)
+	assert.NotNil(t, e)
+}
+
+// only tells the controllers apart
+type fakeController struct {
+	joycon.Controller
+	mac string
+}
+
+// two controllers in one session, each flicks while the other one is held at the edge
+func TestGesturePerStick(t *testing.T) {
+	trig, e := parseTrigger(`stick`, []string{`-side`, `Left`, `-gesture`, `flick`, `-dir`, `Up`})
+	assert.Nil(t, e)
+	cond := trig.(*StickGestureTrigger).condition
+
+	a, b := &fakeController{mac: "a"}, &fakeController{mac: "b"}
+	stick := func(jc joycon.Controller, r joycon.Ratio) bool {
+		return cond.Satisfy(&Input{Type: InputType_Stick, Jc: jc,
+			StickInput: &StickInput{Side: joycon.SideLeft, Ratio: &r}})
+	}
+
+	assert.False(t, stick(a, polar(1, 90)))
+	assert.False(t, stick(b, polar(1, 0)))
+	assert.True(t, stick(a, joycon.Ratio{}))
+	assert.False(t, stick(b, joycon.Ratio{})) // it's a flick Right
+}
@@ -12,6 +12,7 @@ const (
 	InputType_Stick
 	InputType_Gyro
 	InputType_Speech
+	InputType_Key
 )
 
 type ButtonInput struct {
@@ -25,7 +26,15 @@ type StickInput struct {
 type SpeechInput struct {
 	Text string
 }
+
+// a key of other input devices, like a foot pedal
+type KeyInput struct {
+	Device  string // the name in config
+	Code    uint16 // evdev key code
+	Pressed bool
+}
 type Gyro struct {
+	Side  joycon.JoyConSide // which IMU, SideBoth for the Pro Controller
 	Frame *joycon.GyroFrame
 }
 
@@ -34,6 +43,9 @@ type Input struct {
 
 	Jc joycon.Controller
 
+	// the session handling it, set by `ModeManager.Handle()`
+	mgr *ModeManager
+
 	// button
 	*ButtonInput
 
@@ -45,4 +57,7 @@ type Input struct {
 
 	// text
 	*SpeechInput
+
+	// key
+	*KeyInput
 }
@@ -3,7 +3,9 @@ package mode
 import (
 	"fmt"
 
+	"github.com/aj3423/joy-typing/joycon"
 	"github.com/aj3423/joy-typing/voice"
+	log "github.com/sirupsen/logrus"
 )
 
 // mode it self does nothing,
@@ -20,6 +22,13 @@ type mode interface {
 
 	SetSwitches(map[switch_]modifier)
 	SetActions([]trigger)
+
+	// lights indicating this mode, nil to leave it unchanged
+	SetLights(player *byte, home *joycon.HomeLight)
+	ApplyLights(joycon.Controller)
+
+	// the session it belongs to
+	bind(*ModeManager)
 }
 
 // `Mode` is a container of modifier switches and action triggers,
@@ -30,6 +39,11 @@ type Mode struct {
 	switches map[switch_]modifier
 
 	actions []trigger // handlers to *Input event
+
+	lights    *byte // player lights
+	homeLight *joycon.HomeLight
+
+	mgr *ModeManager
 }
 
 func (m *Mode) OnEnter(*Input) error {
@@ -50,6 +64,26 @@ func (m *Mode) Id() string { return m.id }
 func (m *Mode) SetActions(t []trigger)              { m.actions = t }
 func (m *Mode) SetSwitches(sw map[switch_]modifier) { m.switches = sw }
 
+func (m *Mode) bind(mgr *ModeManager) { m.mgr = mgr }
+
+func (m *Mode) SetLights(player *byte, home *joycon.HomeLight) {
+	m.lights, m.homeLight = player, home
+}
+
+// It waits for the reply, don't call it from the event callback
+func (m *Mode) ApplyLights(jc joycon.Controller) {
+	if m.lights != nil {
+		if e := jc.SetLights(*m.lights); e != nil {
+			log.Warnf("fail to set lights of mode '%s': %s", m.id, e.Error())
+		}
+	}
+	if m.homeLight != nil {
+		if e := jc.SetHomeLight(m.homeLight); e != nil {
+			log.Warnf("fail to set HOME light of mode '%s': %s", m.id, e.Error())
+		}
+	}
+}
+
 func (m *Mode) Handle(in *Input) {
 	// Turn modifier switches on/off
 	for swch, modi := range m.switches {
@@ -92,12 +126,17 @@ func NewGyroMode(modeId string) *GyroMode {
 	return g
 }
 
+// the controller is nil for speech or a key device without controller
 func (g *GyroMode) OnEnter(in *Input) error {
-	go in.Jc.EnableGyro(true)
+	if in != nil && in.Jc != nil {
+		go in.Jc.EnableGyro(true)
+	}
 	return g.Mode.OnEnter(in)
 }
 func (g *GyroMode) OnExit(in *Input) error {
-	go in.Jc.EnableGyro(false)
+	if in != nil && in.Jc != nil {
+		go in.Jc.EnableGyro(false)
+	}
 	return g.Mode.OnExit(nil)
 }
 
@@ -158,7 +197,7 @@ func (sp *SpeechMode) OnEnter(*Input) error {
 		// handle speech recognition result from engine
 		sp.recEngine.SetCallback(func(result string) {
 			if len(result) > 0 {
-				Manager.Handle(&Input{
+				sp.mgr.Handle(&Input{
 					Type: InputType_Speech,
 					SpeechInput: &SpeechInput{
 						Text: result,
@@ -3,14 +3,18 @@ package mode
 import (
 	"fmt"
 	"sync"
-)
 
-// global variable
-var Manager = &ModeManager{}
+	"github.com/aj3423/joy-typing/joycon"
+)
 
+// A mode session, each controller or alias has its own one,
+// so they switch modes independently.
 type ModeManager struct {
 	mu sync.RWMutex
 
+	// the alias or MAC of the controllers using it
+	name string
+
 	// all modes indexed by mode.Id
 	map_ map[string]mode
 
@@ -26,8 +30,20 @@ type ModeManager struct {
 	defaultMode mode
 
 	currentMode mode
+
+	// the last recognized text, for [repeat]
+	lastSpeech string
+
+	// the last controller that sent input, for exiting modes like gyro in `Reset()`
+	jc joycon.Controller
 }
 
+func NewModeManager(name string) *ModeManager {
+	return &ModeManager{name: name}
+}
+
+func (l *ModeManager) Name() string { return l.name }
+
 // Set modes from configuration file,
 // the first is set as default mode
 func (l *ModeManager) SetModes(list []mode, modeSwitches []switch_) error {
@@ -38,6 +54,7 @@ func (l *ModeManager) SetModes(list []mode, modeSwitches []switch_) error {
 
 	l.map_ = make(map[string]mode)
 	for _, m := range list {
+		m.bind(l)
 		if _, exist := l.map_[m.Id()]; exist {
 			return fmt.Errorf("duplicated mode id: %s", m.Id())
 		}
@@ -52,6 +69,11 @@ func (l *ModeManager) Handle(in *Input) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	in.mgr = l
+	if in.Jc != nil {
+		l.jc = in.Jc
+	}
+
 	// check if it's mode switching
 	if l.currentMode == l.defaultMode {
 		// check if it's mode entering
@@ -74,6 +96,19 @@ func (l *ModeManager) Handle(in *Input) {
 	l.currentMode.Handle(in)
 }
 
+// Switch back to the default mode so the current one exits,
+// e.g. the gyro is turned off, before the session is dropped
+func (l *ModeManager) Reset() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.trigExit = nil
+	if l.currentMode == l.defaultMode {
+		return nil
+	}
+	return l.switchTo(l.defaultMode.Id(), &Input{Jc: l.jc})
+}
+
 func (l *ModeManager) switchTo(id string, in *Input) error {
 	m, ok := l.map_[id]
 	if !ok {
@@ -86,7 +121,13 @@ func (l *ModeManager) switchTo(id string, in *Input) error {
 		}
 	}
 	l.currentMode = m
-	return l.currentMode.OnEnter(in)
+	if e := l.currentMode.OnEnter(in); e != nil {
+		return e
+	}
+	if in != nil && in.Jc != nil {
+		go m.ApplyLights(in.Jc)
+	}
+	return nil
 }
 
 func (l *ModeManager) DefaultMode() mode {
@@ -0,0 +1,129 @@
+package mode
+
+import (
+	"testing"
+
+	"github.com/aj3423/joy-typing/joycon"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSession(t *testing.T, name string) *ModeManager {
+	modes, switches, e := ParseModes([]ModeConfig{
+		{
+			Mode:  `[idle] -id idle`,
+			Rules: []string{`[switch] button -id ZR -> [mode] -id other`},
+		},
+		{Mode: `[idle] -id other`},
+	})
+	assert.Nil(t, e)
+
+	mm := NewModeManager(name)
+	assert.Nil(t, mm.SetModes(modes, switches))
+	return mm
+}
+
+func pressButton(mm *ModeManager, id joycon.ButtonID, down bool) {
+	var btn, none joycon.ButtonState
+	btn.Set(id)
+
+	in := &ButtonInput{Curr: &btn, Down: &btn, Up: &none}
+	if !down {
+		in = &ButtonInput{Curr: &none, Down: &none, Up: &btn}
+	}
+	mm.Handle(&Input{Type: InputType_Button, ButtonInput: in})
+}
+
+func TestSessionsAreIndependent(t *testing.T) {
+	a, b := newTestSession(t, "alice"), newTestSession(t, "bob")
+	assert.Equal(t, "alice", a.Name())
+
+	pressButton(a, joycon.Button_R_ZR, true)
+	assert.Equal(t, "other", a.CurrentMode().Id())
+	assert.Equal(t, "idle", b.CurrentMode().Id())
+
+	pressButton(b, joycon.Button_R_ZR, true)
+	pressButton(a, joycon.Button_R_ZR, false)
+	assert.Equal(t, "idle", a.CurrentMode().Id())
+	assert.Equal(t, "other", b.CurrentMode().Id())
+}
+
+func pressKey(mm *ModeManager, device string, code uint16, pressed bool) {
+	mm.Handle(&Input{Type: InputType_Key,
+		KeyInput: &KeyInput{Device: device, Code: code, Pressed: pressed}})
+}
+
+func TestKeySwitch(t *testing.T) {
+	modes, switches, e := ParseModes([]ModeConfig{
+		{
+			Mode:  `[idle] -id idle`,
+			Rules: []string{`[switch] key -device pedal -code BTN_0 -> [mode] -id other`},
+		},
+		{Mode: `[idle] -id other`},
+	})
+	assert.Nil(t, e)
+	mm := NewModeManager("alice")
+	assert.Nil(t, mm.SetModes(modes, switches))
+
+	pressKey(mm, "pad", 0x100, true) // another device
+	assert.Equal(t, "idle", mm.CurrentMode().Id())
+
+	pressKey(mm, "pedal", 0x100, true)
+	assert.Equal(t, "other", mm.CurrentMode().Id())
+	pressKey(mm, "pedal", 0x100, false)
+	assert.Equal(t, "idle", mm.CurrentMode().Id())
+
+	_, _, e = ParseModes([]ModeConfig{{
+		Mode:  `[idle] -id idle`,
+		Rules: []string{`[trigger] key -code NO_SUCH_KEY -> [click]`},
+	}})
+	assert.NotNil(t, e)
+}
+
+func TestReset(t *testing.T) {
+	mm := newTestSession(t, "alice")
+	pressButton(mm, joycon.Button_R_ZR, true)
+	assert.Equal(t, "other", mm.CurrentMode().Id())
+
+	assert.Nil(t, mm.Reset())
+	assert.Equal(t, "idle", mm.CurrentMode().Id())
+
+	// the release doesn't affect the default mode
+	pressButton(mm, joycon.Button_R_ZR, false)
+	assert.Equal(t, "idle", mm.CurrentMode().Id())
+}
+
+func tilt(side joycon.JoyConSide, roll float64) *Input {
+	f := &joycon.GyroFrame{}
+	f.Orientation.Roll = roll
+	return &Input{Type: InputType_Gyro, Gyro: &Gyro{Side: side, Frame: f}}
+}
+
+func TestTiltSwitch(t *testing.T) {
+	ts := NewTiltSwitch(joycon.SideInvalid, "roll", 30, 90)
+
+	// enters and leaves in consecutive samples
+	assert.Equal(t, SwitchedOn, ts.Handle(tilt(joycon.SideLeft, 45)))
+	assert.Equal(t, SwitchedOff, ts.Handle(tilt(joycon.SideLeft, 0)))
+
+	// the other IMU of a paired one isn't tilted, it doesn't flip the left one
+	assert.Equal(t, SwitchedOn, ts.Handle(tilt(joycon.SideLeft, 45)))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, SwitchNotChange, ts.Handle(tilt(joycon.SideRight, 0)))
+		assert.Equal(t, SwitchNotChange, ts.Handle(tilt(joycon.SideLeft, 45)))
+	}
+}
+
+func TestLegacyGyroSpeed(t *testing.T) {
+	modes, _, e := ParseModes([]ModeConfig{{
+		Mode: `[idle] -id idle`,
+		Rules: []string{
+			`[trigger] gyro -> [cursor] -speed 0.03`,
+			`[trigger] stick -side Right -> [cursor] -speed 0.5`,
+		},
+	}})
+	assert.Nil(t, e)
+
+	triggers := modes[0].(*IdleMode).actions
+	assert.InDelta(t, 30, triggers[0].GetAction().(*MoveCursor).speed, 1e-9)
+	assert.InDelta(t, 0.5, triggers[1].GetAction().(*MoveCursor).speed, 1e-9) // not gyro
+}
@@ -53,5 +53,8 @@ func (cb *CursorBoost) Modify(in *Input) {
 		in.Frame.Roll = int16(float64(in.Frame.Roll) * cb.multiplier)
 		in.Frame.Yaw = int16(float64(in.Frame.Yaw) * cb.multiplier)
 		in.Frame.Pitch = int16(float64(in.Frame.Pitch) * cb.multiplier)
+		in.Frame.Rotation.Roll *= cb.multiplier
+		in.Frame.Rotation.Yaw *= cb.multiplier
+		in.Frame.Rotation.Pitch *= cb.multiplier
 	}
 }
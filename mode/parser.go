@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/aj3423/joy-typing/evdev"
 	"github.com/aj3423/joy-typing/joycon"
 	"github.com/alexflint/go-arg"
 	"github.com/mattn/go-shellwords"
@@ -43,7 +45,13 @@ func splitArrowLine(line string) (left, right []string, e error) {
 }
 
 func Parse() ([]mode, []switch_, error) {
-	if len(ModeList) == 0 {
+	return ParseModes(ModeList)
+}
+
+// Parse a mode list, each call returns new instances,
+// so each session has its own modes.
+func ParseModes(list []ModeConfig) ([]mode, []switch_, error) {
+	if len(list) == 0 {
 		return nil, nil, errors.New("no mode rules configured")
 	}
 
@@ -52,7 +60,7 @@ func Parse() ([]mode, []switch_, error) {
 	// all hotkeys for switching mode
 	retModeSwitches := []switch_{}
 
-	for modeIndex, modeBlock := range ModeList {
+	for modeIndex, modeBlock := range list {
 		var m mode
 		var actions []trigger                     // actions of above mode
 		var switches = make(map[switch_]modifier) // modifiers of above mode
@@ -92,6 +100,9 @@ func Parse() ([]mode, []switch_, error) {
 				if e != nil {
 					return nil, nil, fmt.Errorf("wrong action: %s, %s", line, e.Error())
 				}
+				if mc, ok := a.(*MoveCursor); ok && isGyroTrigger(t) {
+					mc.upgradeGyroSpeed(line)
+				}
 				t.SetAction(a) // bind action to trigger
 				actions = append(actions, t)
 
@@ -156,6 +167,49 @@ func Parse() ([]mode, []switch_, error) {
 
 	return retModes, retModeSwitches, nil
 }
+
+// shared by `[trigger] tilt` and `[switch] tilt`
+type tiltGrammar struct {
+	Side     string
+	Axis     string `arg:"required"`
+	Min, Max float64
+}
+
+func newTiltGrammar() tiltGrammar {
+	return tiltGrammar{Min: -180, Max: 180}
+}
+
+// returns the side
+func (g *tiltGrammar) check() (joycon.JoyConSide, error) {
+	if _, ok := TiltAxes[g.Axis]; !ok {
+		return joycon.SideInvalid, fmt.Errorf("invalid axis: %s, should be roll/pitch/yaw", g.Axis)
+	}
+	if g.Min > g.Max {
+		return joycon.SideInvalid, fmt.Errorf("min %f > max %f", g.Min, g.Max)
+	}
+	if g.Side == "" { // any side
+		return joycon.SideInvalid, nil
+	}
+	side, valid := joycon.SideMap[g.Side]
+	if !valid {
+		return joycon.SideInvalid, fmt.Errorf("unsupported JoyCon side: %s", g.Side)
+	}
+	return side, nil
+}
+
+// button names -> ids, for parameter like `-with ZL L`
+func parseButtons(names []string) ([]joycon.ButtonID, error) {
+	ret := []joycon.ButtonID{}
+	for _, name := range names {
+		btnId, ok := joycon.ButtonFromString(name)
+		if !ok {
+			return nil, fmt.Errorf("no button named: %s", name)
+		}
+		ret = append(ret, btnId)
+	}
+	return ret, nil
+}
+
 func parseTrigger(name string, args []string) (trigger, error) {
 	lname := strings.ToLower(name)
 
@@ -164,6 +218,7 @@ func parseTrigger(name string, args []string) (trigger, error) {
 		grammar := &struct {
 			Id       string `arg:"required"`
 			WhenDown bool
+			With     []string
 		}{WhenDown: true}
 
 		e := parseArg(grammar, args)
@@ -176,12 +231,36 @@ func parseTrigger(name string, args []string) (trigger, error) {
 		if !ok {
 			return nil, fmt.Errorf("no button named: %s", grammar.Id)
 		}
-		return NewButtonTrigger(btnId, grammar.WhenDown, nil), nil
+		with, e := parseButtons(grammar.With)
+		if e != nil {
+			return nil, e
+		}
+		return NewButtonTrigger(btnId, grammar.WhenDown, with, nil), nil
+
+	case `key`:
+		grammar := &struct {
+			Device   string
+			Code     string `arg:"required"`
+			WhenDown bool
+		}{WhenDown: true}
+
+		e := parseArg(grammar, args)
+		if e != nil {
+			return nil, fmt.Errorf("wrong 'key' args: %s", e.Error())
+		}
+		code, ok := evdev.KeyFromString(grammar.Code)
+		if !ok {
+			return nil, fmt.Errorf("no key code named: %s", grammar.Code)
+		}
+		return NewKeyTrigger(grammar.Device, code, grammar.WhenDown, nil), nil
 
 	case `stick`:
 		grammar := &struct {
-			Side string `arg:"required"`
-			Dir  string
+			Side    string `arg:"required"`
+			Dir     string
+			Gesture string
+			Seq     []string
+			Window  int // ms
 		}{}
 		e := parseArg(grammar, args)
 
@@ -193,14 +272,58 @@ func parseTrigger(name string, args []string) (trigger, error) {
 			return nil, fmt.Errorf("unsupported JoyCon side: %s", grammar.Side)
 		}
 
-		direction, exist := joycon.SpinDirectionMap[grammar.Dir]
-		if exist {
-			return NewStickDirectionTrigger(side, direction, nil), nil
-		} else {
+		if grammar.Gesture != "" {
+			window := time.Duration(grammar.Window) * time.Millisecond
+			if _, e := newGesture(grammar.Gesture, grammar.Dir, grammar.Seq, window); e != nil {
+				return nil, e
+			}
+			// a new one for each stick, the args are checked above
+			return NewStickGestureTrigger(side, func() gesture {
+				g, _ := newGesture(grammar.Gesture, grammar.Dir, grammar.Seq, window)
+				return g
+			}, nil), nil
+		}
+
+		if grammar.Dir == "" {
 			return NewStickMoveTrigger(side, nil), nil
 		}
+		direction, exist := joycon.SpinDirectionMap[grammar.Dir]
+		if !exist {
+			return nil, fmt.Errorf("invalid direction: %s", grammar.Dir)
+		}
+		return NewStickDirectionTrigger(side, direction, nil), nil
 	case `gyro`:
-		return NewGyroTrigger(nil), nil
+		grammar := &struct {
+			Side string
+		}{}
+		e := parseArg(grammar, args)
+		if e != nil {
+			return nil, fmt.Errorf("wrong 'gyro' args: %s", e.Error())
+		}
+
+		side := joycon.JoyConSide(joycon.SideInvalid) // any side
+		if grammar.Side != "" {
+			var valid bool
+			side, valid = joycon.SideMap[grammar.Side]
+			if !valid {
+				return nil, fmt.Errorf("unsupported JoyCon side: %s", grammar.Side)
+			}
+		}
+		return NewGyroTrigger(side, nil), nil
+	case `tilt`:
+		grammar := &struct {
+			tiltGrammar
+			Leave bool
+		}{tiltGrammar: newTiltGrammar()}
+		e := parseArg(grammar, args)
+		if e != nil {
+			return nil, fmt.Errorf("wrong 'tilt' args: %s", e.Error())
+		}
+		side, e := grammar.check()
+		if e != nil {
+			return nil, e
+		}
+		return NewTiltTrigger(side, grammar.Axis, grammar.Min, grammar.Max, !grammar.Leave, nil), nil
 	case `speech`:
 		return NewSpeechTrigger(nil), nil
 	default:
@@ -215,7 +338,8 @@ func parseSwitch(
 	switch lname {
 	case `button`:
 		grammar := &struct {
-			Id string `arg:"required"`
+			Id   string `arg:"required"`
+			With []string
 		}{}
 
 		e := parseArg(grammar, args)
@@ -228,7 +352,26 @@ func parseSwitch(
 		if !ok {
 			return nil, fmt.Errorf("no button named: %s", grammar.Id)
 		}
-		return NewButtonSwitch(btnId), nil
+		with, e := parseButtons(grammar.With)
+		if e != nil {
+			return nil, e
+		}
+		return NewButtonSwitch(btnId, with), nil
+	case `key`:
+		grammar := &struct {
+			Device string
+			Code   string `arg:"required"`
+		}{}
+
+		e := parseArg(grammar, args)
+		if e != nil {
+			return nil, fmt.Errorf("wrong 'key' args: %s", e.Error())
+		}
+		code, ok := evdev.KeyFromString(grammar.Code)
+		if !ok {
+			return nil, fmt.Errorf("no key code named: %s", grammar.Code)
+		}
+		return NewKeySwitch(grammar.Device, code), nil
 	case `stick`:
 		grammar := &struct {
 			Side string `arg:"required"`
@@ -250,6 +393,17 @@ func parseSwitch(
 			return nil, fmt.Errorf("invalid direction: %s", grammar.Dir)
 		}
 		return NewStickDirectionSwitch(side, direction), nil
+	case `tilt`:
+		grammar := newTiltGrammar()
+		e := parseArg(&grammar, args)
+		if e != nil {
+			return nil, fmt.Errorf("wrong 'tilt' args: %s", e.Error())
+		}
+		side, e := grammar.check()
+		if e != nil {
+			return nil, e
+		}
+		return NewTiltSwitch(side, grammar.Axis, grammar.Min, grammar.Max), nil
 	default:
 		return nil, fmt.Errorf("no switch named: %s", name)
 	}
@@ -338,6 +492,16 @@ func parseAction(name string, args []string) (action, error) {
 		}
 		return NewExecSpeech(grammar.Number, grammar.NoSpace, grammar.Typing, grammar.Map)
 
+	case `[rumble]`:
+		grammar := &struct {
+			Pattern string
+		}{Pattern: "tick"}
+		e := parseArg(grammar, args)
+		if e != nil {
+			return nil, e
+		}
+		return NewRumble(grammar.Pattern)
+
 	case `[flush]`:
 		return &FlushVoice{}, nil
 	case `[repeat]`:
@@ -354,24 +518,58 @@ func parseAction(name string, args []string) (action, error) {
 		return nil, fmt.Errorf("unknown action: %s", name)
 	}
 }
+
+// lights indicating the mode, common to all modes
+type lightGrammar struct {
+	Lights string // player lights, e.g. 1f00
+	Home   string // HOME light pattern name
+}
+
+func (g *lightGrammar) apply(m mode) error {
+	var player *byte
+	if g.Lights != "" {
+		p, e := joycon.ParseLights(g.Lights)
+		if e != nil {
+			return e
+		}
+		player = &p
+	}
+	var home *joycon.HomeLight
+	if g.Home != "" {
+		h, ok := joycon.HomeLightPatterns[g.Home]
+		if !ok {
+			return fmt.Errorf("unknown HOME light pattern: %s", g.Home)
+		}
+		home = h
+	}
+	m.SetLights(player, home)
+	return nil
+}
+
 func parseMode(name string, args []string) (mode, error) {
 	lname := strings.ToLower(name)
 
+	var m mode
+	var lights *lightGrammar
+	var e error
+
 	switch lname {
 
 	case `[idle]`:
 		grammar := &struct {
 			Id string `arg:"required"`
+			lightGrammar
 		}{}
-		e := parseArg(grammar, args)
-		return NewIdleMode(grammar.Id), e
+		e = parseArg(grammar, args)
+		m, lights = NewIdleMode(grammar.Id), &grammar.lightGrammar
 
 	case `[gyro]`:
 		grammar := &struct {
 			Id string `arg:"required"`
+			lightGrammar
 		}{}
-		e := parseArg(grammar, args)
-		return NewGyroMode(grammar.Id), e
+		e = parseArg(grammar, args)
+		m, lights = NewGyroMode(grammar.Id), &grammar.lightGrammar
 
 	case `[speech]`:
 		grammar := &struct {
@@ -380,14 +578,20 @@ func parseMode(name string, args []string) (mode, error) {
 			Phrase      []string
 			Engine      string
 			FlushOnExit bool
+			lightGrammar
 		}{Engine: "vosk", Host: "localhost:2701"}
-		e := parseArg(grammar, args)
+		e = parseArg(grammar, args)
 		if e != nil {
 			return nil, e
 		}
-		return NewSpeechMode(grammar.Id, grammar.Engine, grammar.Host, grammar.Phrase, grammar.FlushOnExit)
+		m, e = NewSpeechMode(grammar.Id, grammar.Engine, grammar.Host, grammar.Phrase, grammar.FlushOnExit)
+		lights = &grammar.lightGrammar
 
 	default:
 		return nil, fmt.Errorf("no mode named: '%s'", name)
 	}
+	if e != nil {
+		return nil, e
+	}
+	return m, lights.apply(m)
 }
@@ -63,13 +63,27 @@ type ButtonSwitch struct {
 	Switch
 }
 
-func NewButtonSwitch(btnId joycon.ButtonID) *ButtonSwitch {
+// `with` buttons are only required for turning it on,
+// releasing `btnId` alone turns it off.
+func NewButtonSwitch(btnId joycon.ButtonID, with []joycon.ButtonID) *ButtonSwitch {
 	bs := &ButtonSwitch{}
-	bs.SetOnTrigger(NewButtonTrigger(btnId, true, nil))
-	bs.SetOffTrigger(NewButtonTrigger(btnId, false, nil))
+	bs.SetOnTrigger(NewButtonTrigger(btnId, true, with, nil))
+	bs.SetOffTrigger(NewButtonTrigger(btnId, false, nil, nil))
 	return bs
 }
 
+// A switch that is turned on/off by a key of other input devices, like a foot pedal
+type KeySwitch struct {
+	Switch
+}
+
+func NewKeySwitch(device string, code uint16) *KeySwitch {
+	ks := &KeySwitch{}
+	ks.SetOnTrigger(NewKeyTrigger(device, code, true, nil))
+	ks.SetOffTrigger(NewKeyTrigger(device, code, false, nil))
+	return ks
+}
+
 // A switch that is turned on On/Off by spinning stick to the specified direction
 type StickDirectionSwitch struct {
 	Switch
@@ -87,5 +101,20 @@ func NewStickDirectionSwitch(side joycon.JoyConSide, dir joycon.SpinDirection) *
 	return ss
 }
 
+// A switch that is turned on when the controller is tilted into the angle range,
+// and off when it leaves
+type TiltSwitch struct {
+	Switch
+}
+
+func NewTiltSwitch(side joycon.JoyConSide, axis string, min, max float64) *TiltSwitch {
+	// both triggers share the range, so either one sees every input
+	r := newTiltRange(side, axis, min, max)
+	ts := &TiltSwitch{}
+	ts.SetOnTrigger(newTiltTrigger(r, true, nil))
+	ts.SetOffTrigger(newTiltTrigger(r, false, nil))
+	return ts
+}
+
 // Use two words to turn the switch on/off
 // type VoiceSwitch struct {}
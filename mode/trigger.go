@@ -51,17 +51,29 @@ type ButtonTrigger struct {
 }
 
 func NewButtonTrigger(
-	btnId joycon.ButtonID, whenDown bool, a action,
+	btnId joycon.ButtonID, whenDown bool, with []joycon.ButtonID, a action,
 ) *ButtonTrigger {
 	b := &ButtonTrigger{}
 
 	b.condition = &ButtonCondition{
-		whenDown: whenDown, btnId: btnId,
+		whenDown: whenDown, btnId: btnId, with: with,
 	}
 	b.action = a
 	return b
 }
 
+type KeyTrigger struct {
+	Trigger
+}
+
+func NewKeyTrigger(device string, code uint16, whenDown bool, a action) *KeyTrigger {
+	t := &KeyTrigger{}
+
+	t.condition = &KeyCondition{device: device, code: code, whenDown: whenDown}
+	t.action = a
+	return t
+}
+
 type StickMoveTrigger struct {
 	Trigger
 }
@@ -95,6 +107,24 @@ func NewStickDirectionTrigger(
 	return t
 }
 
+type StickGestureTrigger struct {
+	Trigger
+}
+
+func NewStickGestureTrigger(
+	side joycon.JoyConSide, newGesture func() gesture, a action,
+) *StickGestureTrigger {
+	t := &StickGestureTrigger{}
+
+	t.condition = &StickGestureCondition{
+		side:       side,
+		newGesture: newGesture,
+		gestures:   make(map[sourceKey]gesture),
+	}
+	t.action = a
+	return t
+}
+
 type SpeechTrigger struct {
 	Trigger
 }
@@ -114,11 +144,38 @@ type GyroTrigger struct {
 }
 
 func NewGyroTrigger(
-	a action,
+	side joycon.JoyConSide, a action,
 ) *GyroTrigger {
 	t := &GyroTrigger{}
 
-	t.condition = &GyroCondition{}
+	t.condition = &GyroCondition{side: side}
+	t.action = a
+	return t
+}
+
+// triggered by gyro input
+func isGyroTrigger(t trigger) bool {
+	switch t.(type) {
+	case *GyroTrigger, *TiltTrigger:
+		return true
+	}
+	return false
+}
+
+type TiltTrigger struct {
+	Trigger
+}
+
+func NewTiltTrigger(
+	side joycon.JoyConSide, axis string, min, max float64, whenEnter bool, a action,
+) *TiltTrigger {
+	return newTiltTrigger(newTiltRange(side, axis, min, max), whenEnter, a)
+}
+
+func newTiltTrigger(r *tiltRange, whenEnter bool, a action) *TiltTrigger {
+	t := &TiltTrigger{}
+
+	t.condition = &TiltCondition{tiltRange: r, whenEnter: whenEnter}
 	t.action = a
 	return t
 }
@@ -229,15 +229,20 @@ func (fac *typingFactory) parse(words []string) (cost int, exec executor, e erro
 	return 1, t, nil       // return 1
 }
 
-type repeat struct{}
+type repeat struct {
+	mgr *ModeManager // the session of the speech, nil when not handled by any
+}
 
 // the member `words` should be groupped when `exec()`
 func (r *repeat) exec(_ *wordArray) error {
+	if r.mgr == nil {
+		return nil
+	}
 	// todo, dynamic parse like `repeat 5`
-	go Manager.Handle(&Input{
+	go r.mgr.Handle(&Input{
 		Type: InputType_Speech,
 		SpeechInput: &SpeechInput{
-			Text: lastSpeech,
+			Text: r.mgr.lastSpeech,
 		},
 	})
 	return nil